@@ -1,6 +1,7 @@
 package wordwrap_test
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/mdigger/wordwrap"
@@ -33,3 +34,39 @@ func Example() {
 	// > ante et, suspendisse aliquam nunc, urna sem a
 	// > ornare sed ante laoreet.
 }
+
+func ExampleWriter_SetHangingIndent() {
+	// reference list with one reference per line
+	source := "Knuth, D. E. The Art of Computer Programming. Addison-Wesley, 1968.\n" +
+		"Lamport, L. LaTeX, 1986.\n" +
+		"Kernighan, B. W. and Ritchie, D. M. The C Programming Language. " +
+		"Prentice Hall, 1978."
+	w := wordwrap.New(os.Stdout, 40) // init wrap writer
+	w.SetHangingIndent(4)            // indent continuation lines
+	w.WriteString(source)            // write references
+	// Output:
+	// Knuth, D. E. The Art of Computer
+	//     Programming. Addison-Wesley, 1968.
+	// Lamport, L. LaTeX, 1986.
+	// Kernighan, B. W. and Ritchie, D. M. The
+	//     C Programming Language. Prentice
+	//     Hall, 1978.
+}
+
+func ExampleWriter_SetPrefixFunc() {
+	// source unwrapped text
+	source := `Lorem ipsum dolor sit amet, lectus sed ut at lacinia. ` +
+		`A adipiscing. Vel placerat, ornare vel consectetur integer. Et ` +
+		`molestie ante mauris, sociis aliqua senectus et.`
+	w := wordwrap.New(os.Stdout, 40) // init wrap writer
+	w.SetPrefixFunc(func(lineNum int) string {
+		return fmt.Sprintf("%02d| ", lineNum+1) // number the lines from 1
+	})
+	w.WriteString(source) // write text
+	// Output:
+	// 01| Lorem ipsum dolor sit amet, lectus
+	// 02| sed ut at lacinia. A adipiscing. Vel
+	// 03| placerat, ornare vel consectetur
+	// 04| integer. Et molestie ante mauris,
+	// 05| sociis aliqua senectus et.
+}
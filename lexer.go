@@ -0,0 +1,241 @@
+package wordwrap
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// lexer holds the rules that split the source text into words, spaces and
+// newlines and find the word break positions. Writer, Tokenizer and Multi are
+// built on it, so they split the text the same way.
+type lexer struct {
+	breakpoints []rune       // additional word break runes
+	wsBreaks    bool         // whitespace breakpoints are ignored
+	wsInvisible bool         // whitespace invisible breaks are ignored
+	leading     []rune       // breakpoints leading the next line
+	invisible   []rune       // word break runes that are not written
+	objects     []object     // embedded objects of a fixed width
+	invalid     InvalidUTF8  // handling of invalid UTF-8
+	invalidRun  bool         // the last byte is invalid UTF-8
+	ansi        bool         // ANSI escape sequences flag
+	caseBreak   bool         // break words on camelCase and snake_case
+	punct       []rune       // punctuation runes to break words after
+	priority    []rune       // breakpoints in order of preference
+	profile     WidthProfile // display width of runes
+	unit        Unit         // width unit
+}
+
+// lexKind defines the class of the rune of the source text.
+type lexKind int
+
+// Classes of the runes.
+const (
+	lexWord      lexKind = iota // rune of the word
+	lexZero                     // rune of the word without width
+	lexRaw                      // invalid UTF-8 byte written as is
+	lexSkip                     // invalid UTF-8 byte that is dropped
+	lexInvisible                // invisible breakpoint
+	lexNewline                  // newline
+	lexSpace                    // whitespace other than newlines
+)
+
+// lexeme is the rune of the source text with its class.
+type lexeme struct {
+	kind lexKind // class of the rune
+	c    rune    // decoded rune
+	size int     // size in bytes
+	raw  byte    // the first byte
+}
+
+// scan returns the rune of a given size decoded from the source text, starting
+// with the raw byte, with its class. It follows the ANSI escape sequences and
+// the runs of invalid UTF-8 bytes, so the runes must be scanned in order.
+func (l *lexer) scan(c rune, size int, raw byte) lexeme {
+	var t = lexeme{c: c, size: size, raw: raw}
+	invalid := c == utf8.RuneError && size == 1
+	switch {
+	case invalid && l.invalid == InvalidPass:
+		t.kind = lexRaw
+	case invalid && l.invalid == InvalidCollapse && l.invalidRun:
+		t.kind = lexSkip
+	case l.ansi: // in ANSI escape sequence
+		t.kind = lexZero
+		l.ansi = !isANSITerminator(c)
+	default:
+		t.kind = l.classify(c)
+		l.ansi = c == '\x1B'
+	}
+	l.invalidRun = invalid
+	return t
+}
+
+// sameScan reports whether l scans the text as o does: it classifies the runes
+// by the same rules and is in the same state.
+func (l *lexer) sameScan(o *lexer) bool {
+	if l.invalid != o.invalid || l.ansi != o.ansi ||
+		l.invalidRun != o.invalidRun || len(l.invisible) != len(o.invisible) {
+		return false
+	}
+	for i, c := range l.invisible {
+		if o.invisible[i] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// lexRune returns the rune c, that is not a part of an ANSI escape sequence,
+// with its class.
+func (l *lexer) lexRune(c rune) lexeme {
+	return lexeme{kind: l.classify(c), c: c, size: utf8.RuneLen(c)}
+}
+
+// classify returns the class of the rune c, that is not a part of an ANSI
+// escape sequence. The cases are checked in order, so the escape, newlines and
+// whitespace are never checked as breakpoints.
+func (l *lexer) classify(c rune) lexKind {
+	switch {
+	case c == '\x1B', c == '\uFEFF': // ANSI escape, zero width no-break space
+		return lexZero
+	case containsRune(l.invisible, c): // dropped break opportunity
+		return lexInvisible
+	case c == '\n':
+		return lexNewline
+	case unicode.IsSpace(c):
+		return lexSpace
+	}
+	return lexWord
+}
+
+// object is the marker of the embedded object of a fixed width.
+type object struct {
+	marker string // marker bytes
+	width  int    // width in columns
+}
+
+// Priorities of the word breaks that are not breakpoints.
+const (
+	punctPriority = -1
+	casePriority  = -2
+)
+
+// breakBefore reports whether the word may be broken before the rune c, that
+// follows the rune last, and returns the priority of the break and whether it
+// is allowed for the word that fits the line. The word may be broken after a
+// breakpoint or a punctuation break, before a leading breakpoint, on a new
+// camelCase hump or after an underscore.
+func (l *lexer) breakBefore(last, c rune) (priority int, fit, ok bool) {
+	if c == zwj || last == zwj {
+		return 0, false, false // the joined runes are not broken
+	}
+	switch {
+	case containsRune(l.leading, c): // break before the rune
+		return l.breakPriority(c), true, true
+	case l.isBreakpoint(last) && !containsRune(l.leading, last):
+		return l.breakPriority(last), true, true
+	case containsRune(l.punct, last):
+		return punctPriority, true, true
+	case l.caseBreak &&
+		(last == '_' || (unicode.IsLower(last) && unicode.IsUpper(c))):
+		return casePriority, false, true
+	}
+	return 0, false, false
+}
+
+// breaksWords reports whether any word breaks, other than the invisible
+// breakpoints, are set.
+func (l *lexer) breaksWords() bool {
+	return l.caseBreak || len(l.punct) > 0 || len(l.breakpoints) > 0 ||
+		len(l.leading) > 0
+}
+
+// breakPriority returns the priority of the breakpoint rune.
+func (l *lexer) breakPriority(c rune) int {
+	for i, r := range l.priority {
+		if r == c {
+			return len(l.priority) - i
+		}
+	}
+	return 0
+}
+
+func (l *lexer) isBreakpoint(c rune) bool {
+	return containsRune(l.breakpoints, c)
+}
+
+func containsRune(runes []rune, c rune) bool {
+	for _, r := range runes {
+		if r == c {
+			return true
+		}
+	}
+	return false
+}
+
+// breakpointRunes returns the unique runes of s that are not whitespace and
+// reports whether some whitespace runes are ignored.
+func breakpointRunes(s string) (runes []rune, ignored bool) {
+	for _, c := range s {
+		switch {
+		case unicode.IsSpace(c):
+			ignored = true
+		case !containsRune(runes, c):
+			runes = append(runes, c)
+		}
+	}
+	return runes, ignored
+}
+
+// runeWidth returns the width of rune c in the width units.
+func (l *lexer) runeWidth(c rune) int {
+	switch l.unit {
+	case UnitBytes:
+		return utf8.RuneLen(c)
+	case UnitDisplay:
+		if c == zwj {
+			return 0 // whatever the profile is
+		}
+		return l.profile.width(c)
+	}
+	return 1
+}
+
+// measure returns the width of s in the width units.
+func (l *lexer) measure(s string) int {
+	switch l.unit {
+	case UnitBytes:
+		return len(s)
+	case UnitDisplay:
+		return l.textWidth([]byte(s))
+	}
+	return utf8.RuneCountInString(s)
+}
+
+// textWidth returns the display width of b in columns, skipping ANSI escape
+// sequences.
+func (l *lexer) textWidth(b []byte) (n int) {
+	var ansi bool
+	for len(b) > 0 {
+		c, size := utf8.DecodeRune(b)
+		b = b[size:]
+		switch {
+		case c == '\x1B':
+			ansi = true
+		case ansi:
+			ansi = !isANSITerminator(c)
+		case c == utf8.RuneError && size == 1 && l.invalid == InvalidPass:
+			// raw byte without width
+		case c != '\uFEFF':
+			n += l.runeWidth(c)
+		}
+	}
+	return n
+}
+
+// isANSITerminator reports whether c terminates ANSI escape sequence.
+func isANSITerminator(c rune) bool {
+	return (c >= 0x40 && c <= 0x5a) || (c >= 0x61 && c <= 0x7a)
+}
+
+// zwj is the zero width joiner.
+const zwj = '\u200D'
@@ -0,0 +1,128 @@
+package wordwrap
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// Config is the configuration of the Writer of Multi.
+type Config struct {
+	Writer  io.Writer // destination of the wrapped text
+	Width   uint      // line width
+	Prefix  string    // prefix of the new lines
+	Options []Option  // other options
+}
+
+// Multi writes the same text wrapped by several Writers, each with its own
+// width, prefix and destination. The text is scanned once: each rune, with its
+// class of a word, space or newline rune, is passed to every Writer, that
+// measures the words, finds the breaks and assembles the lines by its own
+// rules. A Writer that scans the text differently from the others, for example
+// with other invisible breakpoints, handling of invalid UTF-8 or embedded
+// objects, or that writes the text verbatim or without wrapping, is written on
+// its own.
+type Multi struct {
+	writers []*Writer // wrapping writers
+	shared  []*Writer // writers of the shared scan of the current text
+	counts  []int     // bytes of the text consumed by the shared writers
+}
+
+// NewMulti returns a new Multi writing the text with the Writers of a given
+// configurations.
+func NewMulti(configs ...Config) *Multi {
+	var m = &Multi{writers: make([]*Writer, len(configs))}
+	for i, c := range configs {
+		m.writers[i] = NewWithOptions(c.Writer, c.Width, c.Options...)
+		if c.Prefix != "" {
+			m.writers[i].SetPrefix(c.Prefix)
+		}
+	}
+	return m
+}
+
+// Writer returns the i-th Writer. It may be used to configure the wrapping
+// before the first write.
+func (m *Multi) Writer(i int) *Writer {
+	return m.writers[i]
+}
+
+// Write implements io.Writer. The text is written to all Writers, even if some
+// of them fail. It returns the least number of bytes consumed by the Writers
+// and the first write error encountered.
+func (m *Multi) Write(b []byte) (n int, err error) {
+	n = len(b)
+	result := func(size int, werr error) {
+		if size < n {
+			n = size
+		}
+		if werr != nil && err == nil {
+			err = werr
+		}
+	}
+	m.shared, m.counts = m.shared[:0], m.counts[:0]
+	for _, w := range m.writers {
+		if !w.sharesScan(m.shared) {
+			result(w.Write(b))
+			continue
+		}
+		skip, werr := w.skipHead(len(b), func(i int) (rune, int) {
+			return utf8.DecodeRune(b[i:])
+		})
+		if werr != nil {
+			result(0, werr)
+			continue
+		}
+		m.shared = append(m.shared, w)
+		m.counts = append(m.counts, skip)
+	}
+	if len(m.shared) == 0 {
+		return n, err
+	}
+	var src = m.shared[0].lexer
+	for off := 0; off < len(b); {
+		c, size := utf8.DecodeRune(b[off:])
+		t := src.scan(c, size, b[off])
+		for i, w := range m.shared {
+			if off < m.counts[i] || w.dst.err != nil {
+				continue // the head is skipped or the writer is failed
+			}
+			w.srcOff, w.srcEnd = w.consumed+off, w.consumed+off+size
+			w.sourceRune(t)
+			m.counts[i] = off + size
+		}
+		off += size
+	}
+	for i, w := range m.shared {
+		w.writeWord() // output last word
+		w.consumed += m.counts[i]
+		w.ansi, w.invalidRun = src.ansi, src.invalidRun
+		result(m.counts[i], w.dst.err)
+	}
+	return n, err
+}
+
+// sharesScan reports whether the Writer may wrap the text scanned for the
+// shared writers: it wraps the text by runes and scans it as they do.
+func (w *Writer) sharesScan(shared []*Writer) bool {
+	if w.verbatim || w.noWrap() || len(w.objects) > 0 || w.dst.err != nil ||
+		w.dst.truncated {
+		return false
+	}
+	return len(shared) == 0 || w.sameScan(&shared[0].lexer)
+}
+
+// WriteString writes the string to all Writers.
+func (m *Multi) WriteString(s string) (n int, err error) {
+	return m.Write([]byte(s))
+}
+
+// Flush flushes all Writers. It returns the first error encountered.
+func (m *Multi) Flush() error {
+	var err error
+	for _, w := range m.writers {
+		if werr := w.Flush(); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package wordwrap_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mdigger/wordwrap"
+)
+
+func TestMulti(t *testing.T) {
+	const source = "\uFEFFLorem ipsum dolor sit amet, lectus sed ut at lacinia.\n" +
+		"A adipiscing. Vel placerat, ornare vel consectetur integer."
+	var narrow, wide, bullet bytes.Buffer
+	m := wordwrap.NewMulti(
+		wordwrap.Config{Writer: &narrow, Width: 20, Prefix: "> "},
+		wordwrap.Config{Writer: &wide, Width: 40},
+		wordwrap.Config{Writer: &bullet, Width: 30, Options: []wordwrap.Option{
+			func(w *wordwrap.Writer) { w.SetHangingBullet("* ") },
+		}},
+	)
+	m.Writer(1).SetStripBOM(true)
+	for _, s := range []string{source[:20], source[20:]} {
+		n, err := m.WriteString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(s) {
+			t.Errorf("got %d bytes, want %d", n, len(s))
+		}
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		got   *bytes.Buffer
+		width uint
+		opts  []wordwrap.Option
+	}{
+		{&narrow, 20, []wordwrap.Option{wordwrap.WithPrefix("> ")}},
+		{&wide, 40, []wordwrap.Option{func(w *wordwrap.Writer) { w.SetStripBOM(true) }}},
+		{&bullet, 30, []wordwrap.Option{func(w *wordwrap.Writer) { w.SetHangingBullet("* ") }}},
+	} {
+		var want bytes.Buffer
+		w := wordwrap.NewWithOptions(&want, test.width, test.opts...)
+		w.WriteString(source[:20])
+		w.WriteString(source[20:])
+		if test.got.String() != want.String() {
+			t.Errorf("width %d: got %q, want %q", test.width, test.got, want.String())
+		}
+	}
+
+	var trimmed bytes.Buffer
+	m = wordwrap.NewMulti(wordwrap.Config{Writer: &trimmed, Width: 10,
+		Options: []wordwrap.Option{
+			func(w *wordwrap.Writer) { w.SetTrimLeadingSpace(true) },
+		}})
+	if n, err := m.WriteString("   hello world foo"); err != nil || n != 18 {
+		t.Errorf("got %d, %v, want 18, nil", n, err)
+	}
+	if got, want := trimmed.String(), "hello\nworld foo"; got != want {
+		t.Errorf("trimmed: got %q, want %q", got, want)
+	}
+
+	long := strings.Repeat("Съешь же ещё этих мягких французских булок. ", 300) +
+		strings.Repeat("x", 5000)
+	var chunked bytes.Buffer
+	m = wordwrap.NewMulti(wordwrap.Config{Writer: &chunked, Width: 30})
+	if n, err := m.WriteString(long); err != nil || n != len(long) {
+		t.Errorf("got %d, %v, want %d, nil", n, err, len(long))
+	}
+	m.Flush()
+	if got, want := chunked.String(), wordwrap.String(long, 30); got != want {
+		t.Errorf("long text: got %d bytes, want %d", len(got), len(want))
+	}
+
+	spaced := strings.Repeat("aaa bbb ccc ddd eee fff ", 2400)
+	keep := func(w *wordwrap.Writer) { w.SetKeepFinalSpace(true) }
+	var shared, single bytes.Buffer
+	m = wordwrap.NewMulti(wordwrap.Config{Writer: &shared, Width: 17,
+		Options: []wordwrap.Option{keep}})
+	m.WriteString(spaced)
+	m.Flush()
+	w := wordwrap.NewWithOptions(&single, 17, keep)
+	w.WriteString(spaced)
+	w.Flush()
+	if shared.String() != single.String() {
+		t.Errorf("final space: got %d bytes, want %d", shared.Len(), single.Len())
+	}
+}
+
+func TestMultiError(t *testing.T) {
+	var errWrite = errors.New("write error")
+	var buf bytes.Buffer
+	m := wordwrap.NewMulti(
+		wordwrap.Config{Writer: errWriter{errWrite}, Width: 20},
+		wordwrap.Config{Writer: &buf, Width: 20},
+	)
+	if _, err := m.WriteString("Lorem ipsum dolor sit amet"); err != errWrite {
+		t.Errorf("got error %v, want %v", err, errWrite)
+	}
+	if got, want := buf.String(), "Lorem ipsum dolor\nsit amet"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// the count is the least one of the Writers
+	source := strings.Repeat("Lorem ipsum dolor sit amet ", 10)
+	want, _ := wordwrap.New(errWriter{errWrite}, 20).WriteString(source)
+	m = wordwrap.NewMulti(
+		wordwrap.Config{Writer: &buf, Width: 20},
+		wordwrap.Config{Writer: errWriter{errWrite}, Width: 20},
+	)
+	if n, err := m.WriteString(source); n != want || err != errWrite {
+		t.Errorf("got %d, %v, want %d, %v", n, err, want, errWrite)
+	}
+	if want >= len(source) {
+		t.Errorf("got %d bytes consumed by the failed writer", want)
+	}
+}
+
+type errWriter struct{ err error }
+
+func (w errWriter) Write([]byte) (int, error) { return 0, w.err }
@@ -0,0 +1,87 @@
+package wordwrap
+
+import (
+	"bytes"
+	"io"
+)
+
+// Scanner reads text from io.Reader and returns it line by line word-wrapped.
+// The whole wrapped text is not built in memory: each call to Scan wraps only
+// as much of the source as needed for the next line.
+type Scanner struct {
+	reader io.Reader    // source reader
+	writer *Writer      // wrapping writer
+	out    bytes.Buffer // wrapped text
+	in     []byte       // source text not yet wrapped
+	line   []byte       // the current line
+	eof    bool         // source is ended
+	err    error        // first non-EOF error
+}
+
+// NewScanner returns a new Scanner to read wrapped lines from r.
+func NewScanner(r io.Reader, width uint) *Scanner {
+	var s = &Scanner{reader: r}
+	s.writer = New(&s.out, width)
+	return s
+}
+
+// Writer returns the Writer used to wrap the text. It may be used to configure
+// the wrapping before the first call to Scan.
+func (s *Scanner) Writer() *Writer {
+	return s.writer
+}
+
+// Scan advances the Scanner to the next wrapped line, which will then be
+// available through the Text method. It returns false when the scan stops,
+// either by reaching the end of the input or an error.
+func (s *Scanner) Scan() bool {
+	for {
+		if i := bytes.IndexByte(s.out.Bytes(), '\n'); i >= 0 {
+			s.line = append(s.line[:0], s.out.Next(i + 1)[:i]...)
+			return true
+		}
+		if s.eof {
+			if s.out.Len() == 0 {
+				s.line = s.line[:0]
+				return false
+			}
+			s.line = append(s.line[:0], s.out.Next(s.out.Len())...)
+			return true
+		}
+		s.fill()
+	}
+}
+
+// fill reads the next chunk of the source and writes it to the Writer up to the
+// last whitespace, so that the words split between chunks are kept whole.
+func (s *Scanner) fill() {
+	var chunk [4096]byte
+	n, err := s.reader.Read(chunk[:])
+	s.in = append(s.in, chunk[:n]...)
+	if err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		s.eof = true
+		s.writer.Write(s.in)
+		s.in = s.in[:0]
+		if err := s.writer.Flush(); err != nil && s.err == nil {
+			s.err = err
+		}
+		return
+	}
+	if i := bytes.LastIndexAny(s.in, " \t\n\v\f\r"); i >= 0 {
+		s.writer.Write(s.in[:i+1])
+		s.in = append(s.in[:0], s.in[i+1:]...)
+	}
+}
+
+// Text returns the most recent line generated by a call to Scan.
+func (s *Scanner) Text() string {
+	return string(s.line)
+}
+
+// Err returns the first non-EOF error that was encountered by the Scanner.
+func (s *Scanner) Err() error {
+	return s.err
+}
@@ -0,0 +1,60 @@
+package wordwrap_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/mdigger/wordwrap"
+)
+
+func TestScanner(t *testing.T) {
+	const source = "Lorem ipsum dolor sit amet, lectus sed ut at lacinia.\n\n" +
+		"Съешь же ещё этих мягких французских булок"
+	want := []string{
+		"Lorem ipsum dolor",
+		"> sit amet, lectus",
+		"> sed ut at lacinia.",
+		"> ",
+		"> Съешь же ещё этих",
+		"> мягких французских",
+		"> булок",
+	}
+	for name, r := range map[string]io.Reader{
+		"reader":   strings.NewReader(source),
+		"one byte": iotest.OneByteReader(strings.NewReader(source)),
+		"half":     iotest.HalfReader(strings.NewReader(source)),
+	} {
+		s := wordwrap.NewScanner(r, 20)
+		s.Writer().SetPrefix("> ")
+		var got []string
+		for s.Scan() {
+			got = append(got, s.Text())
+		}
+		if err := s.Err(); err != nil {
+			t.Fatal(name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\ngot  %q\nwant %q", name, got, want)
+		}
+	}
+}
+
+func TestScannerError(t *testing.T) {
+	readErr := errors.New("read error")
+	s := wordwrap.NewScanner(io.MultiReader(
+		strings.NewReader("lorem ipsum dolor"), errReader{readErr}), 10)
+	var got []string
+	for s.Scan() {
+		got = append(got, s.Text())
+	}
+	if err := s.Err(); err != readErr {
+		t.Errorf("got error %v, want %v", err, readErr)
+	}
+	if want := []string{"lorem", "ipsum", "dolor"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+package wordwrap
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenType defines the type of the token.
+type TokenType int
+
+// Token types.
+const (
+	TokenWord       TokenType = iota // run of the word runes
+	TokenSpace                       // run of whitespace other than newlines
+	TokenNewline                     // newline: it has no width
+	TokenBreakpoint                  // position where the word may be broken
+)
+
+// Token is the part of the text split by Tokenizer.
+type Token struct {
+	Type     TokenType // token type
+	Text     string    // token text
+	Width    int       // width in the width units
+	Priority int       // priority of the breakpoint
+}
+
+// Tokenizer splits the text into words, spaces, newlines and breakpoints by
+// the rules of the Writer: the same lexer finds the word break positions and
+// measures the words in the same width units.
+//
+// The words are split only at the break positions, that are returned as
+// TokenBreakpoint tokens between the parts of the word: the breakpoint runes
+// stay at the end of the preceding part, the leading breakpoints start the
+// next one. The breakpoint token is empty, unless it is an invisible
+// breakpoint rune, that has no width and is never written by the Writer.
+// Priority is the priority of the breakpoint (see SetBreakpointPriority): it
+// is -1 for the punctuation breaks and -2 for the camelCase and snake_case
+// breaks, that are used only for the words longer than the line.
+//
+// ANSI escape sequences, U+FEFF and invalid UTF-8 bytes written as is are
+// parts of the words and have no width, the embedded objects count as their
+// width. The width of the spaces is their size in bytes, as the Writer counts
+// them: tabs are expanded to the tab stops when the line is assembled. The
+// line assembly, such as the prefixes, quote markers, fill mode, table rows
+// and hyphenation, is left to the caller.
+type Tokenizer struct {
+	lexer
+	src     string // the rest of the text
+	next    lexeme // the rune at the start of the text, if scanned
+	scanned bool   // the next rune is scanned
+	last    rune   // the last rune of the word
+	wordLen int    // width of the whole word
+	broken  bool   // the break before the next rune is returned
+}
+
+// NewTokenizer returns a new Tokenizer of the text with the default rules of
+// the Writer.
+func NewTokenizer(s string) *Tokenizer {
+	return &Tokenizer{src: s}
+}
+
+// Tokenizer returns a new Tokenizer of the text, that splits it by the current
+// rules of the Writer: the breakpoints, the width profile and unit, the
+// objects and the handling of invalid UTF-8.
+func (w *Writer) Tokenizer(s string) *Tokenizer {
+	var t = &Tokenizer{lexer: w.lexer, src: s}
+	t.objects = append([]object(nil), w.objects...)
+	t.ansi, t.invalidRun = false, false
+	return t
+}
+
+// SetBreakpoints sets the breakpoint runes (see Writer.SetBreakpoints).
+func (t *Tokenizer) SetBreakpoints(s string) {
+	t.breakpoints, t.wsBreaks = breakpointRunes(s)
+}
+
+// Next returns the next token of the text. It returns false at the end of the
+// text.
+func (t *Tokenizer) Next() (Token, bool) {
+	var token = Token{Type: TokenWord}
+	var size int
+	for size < len(t.src) {
+		if !t.scanned && !t.ansi {
+			if o, ok := t.object(t.src[size:]); ok {
+				if size > 0 && token.Type != TokenWord {
+					break
+				}
+				size += len(o.marker)
+				token.Width += o.width
+				t.wordLen += o.width
+				t.last, _ = utf8.DecodeLastRuneInString(o.marker)
+				t.broken = false
+				continue
+			}
+		}
+		if !t.scanned {
+			c, n := utf8.DecodeRuneInString(t.src[size:])
+			t.next, t.scanned = t.scan(c, n, t.src[size]), true
+		}
+		l := t.next
+		switch {
+		case l.kind == lexSkip: // dropped with the run of invalid bytes
+		case l.kind == lexNewline:
+			if size == 0 {
+				token.Type = TokenNewline
+				size, t.scanned = l.size, false
+				t.last, t.wordLen, t.broken = 0, 0, false
+			}
+			return t.token(token, size)
+		case l.kind == lexSpace:
+			if size > 0 && token.Type != TokenSpace {
+				return t.token(token, size)
+			}
+			token.Type = TokenSpace
+			token.Width += l.size
+			t.last, t.wordLen, t.broken = 0, 0, false
+		case size > 0 && token.Type != TokenWord:
+			return t.token(token, size)
+		case l.kind == lexInvisible && t.wordLen > 0:
+			if size == 0 {
+				token.Type = TokenBreakpoint
+				size, t.scanned = l.size, false
+			}
+			return t.token(token, size)
+		case l.kind == lexInvisible: // no break at the start of the word
+		case l.kind == lexWord && !t.broken && t.wordLen > 0 && t.breaksWords():
+			if priority, _, ok := t.breakBefore(t.last, l.c); ok {
+				if size == 0 {
+					token.Type, token.Priority = TokenBreakpoint, priority
+					t.broken = true
+				}
+				return t.token(token, size)
+			}
+			fallthrough
+		default:
+			t.word(&token, l)
+		}
+		size, t.scanned = size+l.size, false
+	}
+	if size == 0 {
+		return Token{}, false
+	}
+	return t.token(token, size)
+}
+
+// word adds the rune l to the word token.
+func (t *Tokenizer) word(token *Token, l lexeme) {
+	switch l.kind {
+	case lexWord:
+		token.Width += t.runeWidth(l.c)
+		t.wordLen += t.runeWidth(l.c)
+		t.last = l.c
+	case lexZero:
+		t.last = l.c
+	case lexRaw:
+		t.last = utf8.RuneError
+	}
+	t.broken = false
+}
+
+// token returns the token of the text of a given size and cuts it off.
+func (t *Tokenizer) token(token Token, size int) (Token, bool) {
+	token.Text, t.src = t.src[:size], t.src[size:]
+	return token, true
+}
+
+// object returns the embedded object which marker starts s.
+func (t *Tokenizer) object(s string) (object, bool) {
+	for _, o := range t.objects {
+		if strings.HasPrefix(s, o.marker) {
+			return o, true
+		}
+	}
+	return object{}, false
+}
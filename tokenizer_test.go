@@ -0,0 +1,77 @@
+package wordwrap_test
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/mdigger/wordwrap"
+)
+
+// tokens returns all tokens of the Tokenizer.
+func tokens(tok *wordwrap.Tokenizer) []wordwrap.Token {
+	var got []wordwrap.Token
+	for {
+		token, ok := tok.Next()
+		if !ok {
+			return got
+		}
+		got = append(got, token)
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	const source = "Lorem \x1b[1mipsum\x1b[0m  dolor-sit\t\n\nanti-\uFEFFdis"
+	want := []wordwrap.Token{
+		{wordwrap.TokenWord, "Lorem", 5, 0},
+		{wordwrap.TokenSpace, " ", 1, 0},
+		{wordwrap.TokenWord, "\x1b[1mipsum\x1b[0m", 5, 0},
+		{wordwrap.TokenSpace, "  ", 2, 0},
+		{wordwrap.TokenWord, "dolor-", 6, 0},
+		{wordwrap.TokenBreakpoint, "", 0, 0},
+		{wordwrap.TokenWord, "sit", 3, 0},
+		{wordwrap.TokenSpace, "\t", 1, 0},
+		{wordwrap.TokenNewline, "\n", 0, 0},
+		{wordwrap.TokenNewline, "\n", 0, 0},
+		{wordwrap.TokenWord, "anti-\uFEFFdis", 8, 0}, // no-break space
+	}
+	tok := wordwrap.NewTokenizer(source)
+	tok.SetBreakpoints("-")
+	if got := tokens(tok); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q,\nwant %q", got, want)
+	}
+}
+
+func TestWriterTokenizer(t *testing.T) {
+	const source = "日本|語 a-b/c f(x) [img]x x\xffy"
+	want := []wordwrap.Token{
+		{wordwrap.TokenWord, "日本", 4, 0},
+		{wordwrap.TokenBreakpoint, "|", 0, 0},
+		{wordwrap.TokenWord, "語", 2, 0},
+		{wordwrap.TokenSpace, " ", 1, 0},
+		{wordwrap.TokenWord, "a-", 2, 0},
+		{wordwrap.TokenBreakpoint, "", 0, 0},
+		{wordwrap.TokenWord, "b/", 2, 0},
+		{wordwrap.TokenBreakpoint, "", 0, 1},
+		{wordwrap.TokenWord, "c", 1, 0},
+		{wordwrap.TokenSpace, " ", 1, 0},
+		{wordwrap.TokenWord, "f", 1, 0},
+		{wordwrap.TokenBreakpoint, "", 0, 0},
+		{wordwrap.TokenWord, "(x)", 3, 0},
+		{wordwrap.TokenSpace, " ", 1, 0},
+		{wordwrap.TokenWord, "[img]x", 5, 0},
+		{wordwrap.TokenSpace, " ", 1, 0},
+		{wordwrap.TokenWord, "x\xffy", 2, 0},
+	}
+	w := wordwrap.New(ioutil.Discard, 10)
+	w.SetBreakpoints("-/")
+	w.SetBreakpointPriority("/")
+	w.SetLeadingBreakpoints("(")
+	w.SetInvisibleBreakpoints("|")
+	w.SetWidthProfile(wordwrap.UnicodeTerminal)
+	w.SetObjectWidth("[img]", 4)
+	w.SetInvalidUTF8(wordwrap.InvalidPass)
+	if got := tokens(w.Tokenizer(source)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q,\nwant %q", got, want)
+	}
+}
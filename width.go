@@ -0,0 +1,215 @@
+package wordwrap
+
+import "unicode"
+
+// WidthProfile defines the display width of runes in a terminal. A rune
+// occupies one column, unless the profile defines it as a zero width or a wide
+// one.
+type WidthProfile struct {
+	Wide          func(rune) bool // reports whether the rune occupies two columns
+	ZeroWidth     func(rune) bool // reports whether the rune occupies no columns
+	AmbiguousWide bool            // East Asian ambiguous runes occupy two columns
+}
+
+// Built-in width profiles.
+var (
+	// ASCII counts every rune as one column. It is the default profile.
+	ASCII = WidthProfile{}
+	// UnicodeTerminal counts East Asian wide and fullwidth runes as two
+	// columns and combining marks and format runes as zero columns.
+	UnicodeTerminal = WidthProfile{Wide: IsWide, ZeroWidth: IsZeroWidth}
+)
+
+// width returns the number of columns occupied by rune c.
+func (p WidthProfile) width(c rune) int {
+	switch {
+	case p.ZeroWidth != nil && p.ZeroWidth(c):
+		return 0
+	case p.Wide != nil && p.Wide(c):
+		return 2
+	case p.AmbiguousWide && unicode.Is(ambiguous, c):
+		return 2
+	}
+	return 1
+}
+
+// IsWide reports whether the rune is an East Asian wide or fullwidth rune.
+func IsWide(c rune) bool {
+	return unicode.Is(wide, c)
+}
+
+// IsZeroWidth reports whether the rune is a combining mark, a format rune or a
+// Hangul medial vowel or final consonant, that occupies no columns.
+func IsZeroWidth(c rune) bool {
+	return unicode.In(c, unicode.Mn, unicode.Me, unicode.Cf) ||
+		(c >= 0x1160 && c <= 0x11FF)
+}
+
+// wide is the table of East Asian wide and fullwidth runes.
+var wide = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115F, 1}, // Hangul Jamo initial consonants
+		{0x231A, 0x231B, 1},
+		{0x2329, 0x232A, 1},
+		{0x23E9, 0x23EC, 1},
+		{0x23F0, 0x23F0, 1},
+		{0x23F3, 0x23F3, 1},
+		{0x25FD, 0x25FE, 1},
+		{0x2614, 0x2615, 1},
+		{0x2648, 0x2653, 1},
+		{0x267F, 0x267F, 1},
+		{0x2693, 0x2693, 1},
+		{0x26A1, 0x26A1, 1},
+		{0x26AA, 0x26AB, 1},
+		{0x26BD, 0x26BE, 1},
+		{0x26C4, 0x26C5, 1},
+		{0x26CE, 0x26CE, 1},
+		{0x26D4, 0x26D4, 1},
+		{0x26EA, 0x26EA, 1},
+		{0x26F2, 0x26F3, 1},
+		{0x26F5, 0x26F5, 1},
+		{0x26FA, 0x26FA, 1},
+		{0x26FD, 0x26FD, 1},
+		{0x2705, 0x2705, 1},
+		{0x270A, 0x270B, 1},
+		{0x2728, 0x2728, 1},
+		{0x274C, 0x274C, 1},
+		{0x274E, 0x274E, 1},
+		{0x2753, 0x2755, 1},
+		{0x2757, 0x2757, 1},
+		{0x2795, 0x2797, 1},
+		{0x27B0, 0x27B0, 1},
+		{0x27BF, 0x27BF, 1},
+		{0x2B1B, 0x2B1C, 1},
+		{0x2B50, 0x2B50, 1},
+		{0x2B55, 0x2B55, 1},
+		{0x2E80, 0x303E, 1}, // CJK radicals, symbols and punctuation
+		{0x3041, 0x33FF, 1}, // Hiragana, Katakana, Bopomofo, CJK compatibility
+		{0x3400, 0x4DBF, 1}, // CJK unified ideographs extension A
+		{0x4E00, 0x9FFF, 1}, // CJK unified ideographs
+		{0xA000, 0xA4CF, 1}, // Yi
+		{0xA960, 0xA97F, 1}, // Hangul Jamo extended-A
+		{0xAC00, 0xD7A3, 1}, // Hangul syllables
+		{0xF900, 0xFAFF, 1}, // CJK compatibility ideographs
+		{0xFE10, 0xFE19, 1}, // vertical forms
+		{0xFE30, 0xFE6F, 1}, // CJK compatibility forms, small form variants
+		{0xFF00, 0xFF60, 1}, // fullwidth forms
+		{0xFFE0, 0xFFE6, 1}, // fullwidth signs
+	},
+	R32: []unicode.Range32{
+		{0x16FE0, 0x18AFF, 1}, // Tangut
+		{0x1B000, 0x1B2FF, 1}, // Kana supplement and extended
+		{0x1F004, 0x1F004, 1},
+		{0x1F0CF, 0x1F0CF, 1},
+		{0x1F18E, 0x1F18E, 1},
+		{0x1F191, 0x1F19A, 1},
+		{0x1F200, 0x1F251, 1},
+		{0x1F300, 0x1F64F, 1}, // pictographs and emoticons
+		{0x1F680, 0x1F6FF, 1}, // transport and map symbols
+		{0x1F7E0, 0x1F7EB, 1},
+		{0x1F90C, 0x1F9FF, 1}, // supplemental symbols and pictographs
+		{0x1FA70, 0x1FAFF, 1},
+		{0x20000, 0x2FFFD, 1}, // CJK unified ideographs extensions
+		{0x30000, 0x3FFFD, 1},
+	},
+}
+
+// ambiguous is the table of the main East Asian ambiguous width runes.
+var ambiguous = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x00A1, 0x00A1, 1},
+		{0x00A4, 0x00A4, 1},
+		{0x00A7, 0x00A8, 1},
+		{0x00AA, 0x00AA, 1},
+		{0x00AD, 0x00AE, 1},
+		{0x00B0, 0x00B4, 1},
+		{0x00B6, 0x00BA, 1},
+		{0x00BC, 0x00BF, 1},
+		{0x00C6, 0x00C6, 1},
+		{0x00D0, 0x00D0, 1},
+		{0x00D7, 0x00D8, 1},
+		{0x00DE, 0x00E1, 1},
+		{0x00E6, 0x00E6, 1},
+		{0x00E8, 0x00EA, 1},
+		{0x00EC, 0x00ED, 1},
+		{0x00F0, 0x00F0, 1},
+		{0x00F2, 0x00F3, 1},
+		{0x00F7, 0x00FA, 1},
+		{0x00FC, 0x00FC, 1},
+		{0x00FE, 0x00FE, 1},
+		{0x0391, 0x03A9, 1}, // Greek capital letters
+		{0x03B1, 0x03C9, 1}, // Greek small letters
+		{0x0401, 0x0401, 1},
+		{0x0410, 0x044F, 1}, // Cyrillic letters
+		{0x0451, 0x0451, 1},
+		{0x2010, 0x2010, 1},
+		{0x2013, 0x2016, 1},
+		{0x2018, 0x2019, 1},
+		{0x201C, 0x201D, 1},
+		{0x2020, 0x2022, 1},
+		{0x2024, 0x2027, 1},
+		{0x2030, 0x2030, 1},
+		{0x2032, 0x2033, 1},
+		{0x2035, 0x2035, 1},
+		{0x203B, 0x203B, 1},
+		{0x203E, 0x203E, 1},
+		{0x2103, 0x2103, 1},
+		{0x2109, 0x2109, 1},
+		{0x2116, 0x2116, 1},
+		{0x2121, 0x2122, 1},
+		{0x2153, 0x2154, 1},
+		{0x215B, 0x215E, 1},
+		{0x2160, 0x216B, 1}, // Roman numerals
+		{0x2170, 0x2179, 1},
+		{0x2190, 0x2199, 1}, // arrows
+		{0x2200, 0x2200, 1}, // mathematical operators
+		{0x2202, 0x2203, 1},
+		{0x2207, 0x2208, 1},
+		{0x220B, 0x220B, 1},
+		{0x220F, 0x220F, 1},
+		{0x2211, 0x2211, 1},
+		{0x2215, 0x2215, 1},
+		{0x221A, 0x221A, 1},
+		{0x221D, 0x2220, 1},
+		{0x2223, 0x2223, 1},
+		{0x2225, 0x2225, 1},
+		{0x2227, 0x222C, 1},
+		{0x222E, 0x222E, 1},
+		{0x2234, 0x2237, 1},
+		{0x223C, 0x223D, 1},
+		{0x2248, 0x2248, 1},
+		{0x224C, 0x224C, 1},
+		{0x2252, 0x2252, 1},
+		{0x2260, 0x2261, 1},
+		{0x2264, 0x2267, 1},
+		{0x226A, 0x226B, 1},
+		{0x226E, 0x226F, 1},
+		{0x2282, 0x2283, 1},
+		{0x2286, 0x2287, 1},
+		{0x2295, 0x2295, 1},
+		{0x2299, 0x2299, 1},
+		{0x22A5, 0x22A5, 1},
+		{0x22BF, 0x22BF, 1},
+		{0x2460, 0x24E9, 1}, // enclosed alphanumerics
+		{0x24EB, 0x254B, 1}, // box drawing
+		{0x2550, 0x2573, 1},
+		{0x2580, 0x258F, 1}, // block elements
+		{0x2592, 0x2595, 1},
+		{0x25A0, 0x25A1, 1}, // geometric shapes
+		{0x25B2, 0x25B3, 1},
+		{0x25BC, 0x25BD, 1},
+		{0x25C6, 0x25C8, 1},
+		{0x25CB, 0x25CB, 1},
+		{0x25CE, 0x25D1, 1},
+		{0x2605, 0x2606, 1},
+		{0x2640, 0x2640, 1},
+		{0x2642, 0x2642, 1},
+		{0x2660, 0x2661, 1},
+		{0x2663, 0x2665, 1},
+		{0x2667, 0x266A, 1},
+		{0x266C, 0x266D, 1},
+		{0x266F, 0x266F, 1},
+		{0xE000, 0xF8FF, 1}, // private use area
+		{0xFFFD, 0xFFFD, 1},
+	},
+}
@@ -0,0 +1,94 @@
+package wordwrap_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mdigger/wordwrap"
+)
+
+func TestWidthProfile(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		profile      wordwrap.WidthProfile
+		width        uint
+		source, want string
+	}{
+		{"ascii", wordwrap.ASCII, 12,
+			"日本語の テキスト を 折り返す", "日本語の テキスト を\n折り返す"},
+		{"wide", wordwrap.UnicodeTerminal, 12,
+			"日本語の テキスト を 折り返す", "日本語の\nテキスト を\n折り返す"},
+		{"ascii", wordwrap.ASCII, 15,
+			"cafe\u0301 cafe\u0301 cafe\u0301", "cafe\u0301 cafe\u0301\ncafe\u0301"},
+		{"combining", wordwrap.UnicodeTerminal, 15,
+			"cafe\u0301 cafe\u0301 cafe\u0301", "cafe\u0301 cafe\u0301 cafe\u0301"},
+		{"ascii", wordwrap.ASCII, 15,
+			"αβγ δεζ ηθι", "αβγ δεζ ηθι"},
+		{"ambiguous", wordwrap.WidthProfile{AmbiguousWide: true}, 15,
+			"αβγ δεζ ηθι", "αβγ δεζ\nηθι"},
+		{"custom", wordwrap.WidthProfile{
+			Wide: func(c rune) bool { return c == 'W' }}, 12,
+			"WWW aaa bbb", "WWW aaa\nbbb"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, test.width)
+		w.SetWidthProfile(test.profile)
+		w.WriteString(test.source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWidthUnit(t *testing.T) {
+	const source = "Съешь же ещё этих мягких французских булок"
+	for _, test := range []struct {
+		unit wordwrap.Unit
+		want string
+	}{
+		{wordwrap.UnitRunes, "Съешь же ещё этих\n> мягких французских\n> булок"},
+		{wordwrap.UnitDisplay, "Съешь же ещё этих\n> мягких французских\n> булок"},
+		{wordwrap.UnitBytes, "Съешь же\n> ещё этих\n> мягких\n> французских\n> булок"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 20)
+		w.SetPrefix("> ")
+		w.SetWidthUnit(test.unit)
+		w.WriteString(source)
+		got := buf.String()
+		if got != test.want {
+			t.Errorf("unit %d: got %q, want %q", test.unit, got, test.want)
+		}
+		if test.unit != wordwrap.UnitBytes {
+			continue
+		}
+		for _, line := range strings.Split(got, "\n") {
+			if len(line) > 20 && line != "> французских" {
+				t.Errorf("line %q is %d bytes long", line, len(line))
+			}
+		}
+	}
+}
+
+func TestZeroWidthJoiner(t *testing.T) {
+	// Devanagari conjunct KA, VIRAMA, ZWJ, SSA
+	const joined, conjunct = "क्\u200Dष", "क्ष"
+	for _, test := range []struct {
+		width        uint
+		source, want string
+	}{
+		{8, joined + " " + joined, joined + " " + joined},
+		{4, joined + joined, joined + joined},
+		{4, conjunct + conjunct, "क्\nषक्ष"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, test.width)
+		w.SetWidthProfile(wordwrap.ASCII)
+		w.SetBreakpoints("्") // break after VIRAMA
+		w.WriteString(test.source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
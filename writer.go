@@ -3,71 +3,806 @@ package wordwrap
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
 
-// String is shorthand for declaring a new default Writer instance, used to
-// immediately word-wrap a string.
-func String(s string, width uint) string {
+// String is shorthand for declaring a new Writer instance, configured with
+// the options, used to immediately word-wrap a string.
+func String(s string, width uint, opts ...Option) string {
 	var buf bytes.Buffer
-	var writer = New(&buf, width)
+	var writer = NewWithOptions(&buf, width, opts...)
 	writer.WriteString(s)
+	writer.Flush()
 	return buf.String()
 }
 
-// Bytes is shorthand for declaring a new default Writer instance, used to
-// immediately word-wrap a byte slice.
-func Bytes(b []byte, width uint) []byte {
+// Bytes is shorthand for declaring a new Writer instance, configured with the
+// options, used to immediately word-wrap a byte slice.
+func Bytes(b []byte, width uint, opts ...Option) []byte {
 	var buf bytes.Buffer
-	var writer = New(&buf, width)
+	var writer = NewWithOptions(&buf, width, opts...)
 	writer.Write(b)
+	writer.Flush()
 	return buf.Bytes()
 }
 
+// Option configures the Writer. Any function calling the Writer setters may be
+// used as an option.
+type Option func(*Writer)
+
+// WithPrefix returns the option to set the prefix (see SetPrefix).
+func WithPrefix(s string) Option {
+	return func(w *Writer) { w.SetPrefix(s) }
+}
+
+// WithFirstLinePrefix returns the option to set the prefix of the first line
+// (see SetFirstLinePrefix).
+func WithFirstLinePrefix(s string) Option {
+	return func(w *Writer) { w.SetFirstLinePrefix(s) }
+}
+
+// WithTabWidth returns the option to set the width of tab characters (see
+// SetTabWidth).
+func WithTabWidth(width int) Option {
+	return func(w *Writer) { w.SetTabWidth(width) }
+}
+
+// WithBreakpoints returns the option to set the breakpoints (see
+// SetBreakpoints).
+func WithBreakpoints(s string) Option {
+	return func(w *Writer) { w.SetBreakpoints(s) }
+}
+
+// ErrNoContentWidth is returned by Validate when the prefix leaves no room
+// for content on the wrapped lines.
+var ErrNoContentWidth = errors.New("wordwrap: prefix leaves no room for content")
+
+// ErrIgnoredBreakpoints is returned by Validate when some of the runes passed
+// to SetBreakpoints or SetInvisibleBreakpoints are whitespace and are ignored.
+var ErrIgnoredBreakpoints = errors.New("wordwrap: whitespace breakpoints are ignored")
+
+// ErrNoBuffer is returned by WriteTo and the Reader of a Writer that is not
+// created with NewBuffer.
+var ErrNoBuffer = errors.New("wordwrap: writer has no internal buffer")
+
+// From reads src until EOF or error and returns the word-wrapped text. On a
+// read error it returns the wrapped text read so far and the error.
+func From(src io.Reader, width uint) (string, error) {
+	b, err := ioutil.ReadAll(src)
+	return string(Bytes(b, width)), err
+}
+
+// Height returns the number of lines the word-wrapped string occupies without
+// producing the output.
+func Height(s string, width uint) int {
+	return New(ioutil.Discard, width).Measure(s)
+}
+
+// WrapPositions returns the byte offsets in s where the line breaks are
+// inserted by wrapping, without producing the output.
+func WrapPositions(s string, width uint) []int {
+	return New(ioutil.Discard, width).WrapPositions(s)
+}
+
+// Comment returns the word-wrapped comment block with every line prefixed with
+// the marker, such as "// " or " * ". The marker counts toward the width. The
+// blank lines hold the marker without the trailing spaces.
+func Comment(s string, width uint, marker string) string {
+	text := String(s, width, WithFirstLinePrefix(marker), WithPrefix(marker))
+	if blank := strings.TrimRight(marker, " "); blank != marker {
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			if line == marker {
+				lines[i] = blank
+			}
+		}
+		text = strings.Join(lines, "\n")
+	}
+	return text
+}
+
+// bom is the UTF-8 encoded byte order mark.
+var bom = []byte("\uFEFF")
+
+// newline is the line break of the output.
+var newline = []byte{'\n'}
+
 // Writer wraps UTF-8 encoded text at word boundaries when lines exceed a limit
 // number of characters. Newlines are preserved, including consecutive and
 // trailing newlines, though trailing whitespace is stripped from each line.
 type Writer struct {
-	writer      io.Writer    // default writer
-	width       int          // recommended line length in runes
-	tabWidh     int          // the width of tab characters
-	pos         int          // curent line position
-	space       bytes.Buffer // trailing word spaces
-	word        bytes.Buffer // word builder
-	wordLen     int          // word length in runes
-	newLine     bool         // newline flag
-	prefix      string       // prefix for new line
-	prefixLen   int          // prefix length in runes
-	breakpoints []rune       // additional word break runes
-	ansi        bool         // ANSI escape sequences flag
+	lexer // rules of splitting the text
+
+	writer       io.Writer           // default writer
+	dst          destination         // underlying writer
+	width        int                 // recommended line length in width units
+	nextWidth    int                 // width of the next line
+	widthPending bool                // the width of the next line is set
+	tabWidh      int                 // the width of tab characters
+	tabSpace     bool                // write tabs as spaces if no tab width
+	pos          int                 // curent line position
+	lineStart    int                 // line position after the prefix
+	space        bytes.Buffer        // trailing word spaces
+	word         bytes.Buffer        // word builder
+	wordLen      int                 // word width in columns
+	newLine      bool                // newline flag
+	wrapped      bool                // the line break is inserted by wrapping
+	preferBreaks bool                // break lines on breakpoints before spaces
+	split        wordBreak           // the last breakpoint of the line
+	hanging      int                 // hanging indent of the wrapped lines
+	margin       int                 // left margin of all lines
+	prefix       string              // prefix for new line
+	prefixLen    int                 // prefix length in width units
+	prefixFree   bool                // prefix is not counted toward the width
+	prefixFunc   func(int) string    // prefix by the line number
+	lineNum      int                 // zero-based number of the current line
+	first        string              // prefix for the first line
+	firstLen     int                 // first line prefix length in width units
+	started      bool                // first line prefix flag
+	textStart    int                 // output bytes before the text
+	breaks       []wordBreak         // word break positions
+	closed       bool                // Close was called
+	closeDst     bool                // Close closes the destination
+	ctx          context.Context     // context of WriteContext
+	buf          *bytes.Buffer       // internal output buffer
+	transform    func(string) string // line content transformation
+	line         bytes.Buffer        // line content for transformation
+	rtl          bool                // right-to-left paragraph direction
+	hyphenate    func(string) []int  // word hyphenation points
+	stripBOM     bool                // strip the byte order mark
+	extraSpaces  bool                // carry extra spaces at the break
+	nlSpace      bool                // keep the spaces before a newline
+	trimLead     bool                // skip the leading whitespace of the text
+	trimmed      bool                // the leading whitespace is skipped
+	withStats    bool                // collect wrapping statistics
+	withBreaks   bool                // collect the line breaks
+	withWraps    bool                // collect the wrap positions
+	positions    []int               // wrap positions in the source text
+	consumed     int                 // source bytes written before
+	srcOff       int                 // source offset of the current rune
+	srcEnd       int                 // source offset after the current rune
+	wordEnd      int                 // source offset after the word
+	lineEnd      int                 // source offset after the line content
+	splitEnd     int                 // source offset after the last breakpoint
+	breakList    []Break             // line breaks of the output
+	finalNewLine bool                // end the text with a newline
+	softBreak    []byte              // line break inserted by wrapping
+	marker       string              // continuation marker of wrapped lines
+	markerLen    int                 // marker length in width units
+	finalSpace   bool                // keep the trailing spaces of the text
+	quoteMarker  string              // quote marker without trailing spaces
+	quoting      bool                // quote markers detection flag
+	quoteRun     bytes.Buffer        // detected quote markers
+	quoteMatch   int                 // bytes of the partially matched marker
+	quote        string              // quote markers of the current line
+	quoteLen     int                 // quote markers length in width units
+	verbatim     bool                // fold lines keeping all whitespace
+	fill         bool                // join the source lines into paragraphs
+	newlines     int                 // consecutive newlines held in fill mode
+	tableRows    bool                // write the table rows as is
+	row          bytes.Buffer        // the line collected as a table row
+	inLine       bool                // the source line is started
+	stats        Stats               // wrapping statistics
+}
+
+// Unit defines the unit of the width.
+type Unit int
+
+// Width units.
+const (
+	UnitRunes   Unit = iota // runes (default)
+	UnitBytes               // bytes of the UTF-8 encoding
+	UnitDisplay             // display columns (see SetWidthProfile)
+)
+
+// InvalidUTF8 defines the handling of the invalid UTF-8 bytes of the text.
+type InvalidUTF8 int
+
+// Handling of invalid UTF-8.
+const (
+	InvalidReplace  InvalidUTF8 = iota // replace each byte with U+FFFD (default)
+	InvalidCollapse                    // replace each run of bytes with U+FFFD
+	InvalidPass                        // write the bytes as is without width
+)
+
+// Direction defines the paragraph direction.
+type Direction int
+
+// Paragraph directions.
+const (
+	LTR Direction = iota // left-to-right (default)
+	RTL                  // right-to-left
+)
+
+// Stats is the wrapping statistics of the written text.
+type Stats struct {
+	Lines  int // number of lines
+	MaxLen int // the longest line length in columns
+	Slack  int // the total of the width minus the line length
+}
+
+// AverageSlack returns the average of the width minus the line length.
+func (s Stats) AverageSlack() float64 {
+	if s.Lines == 0 {
+		return 0
+	}
+	return float64(s.Slack) / float64(s.Lines)
+}
+
+// add adds the line of a given length.
+func (s *Stats) add(length, width int) {
+	s.Lines++
+	if length > s.MaxLen {
+		s.MaxLen = length
+	}
+	if length < width {
+		s.Slack += width - length
+	}
 }
 
 // New returns a new initialized wrapper over io.Writer to write lines with
 // word wrap after a given position in the line.
+//
+// The width is inclusive: a line may use all the width columns, including the
+// prefix if it counts toward the width, and it is wrapped only when the next
+// word would end after the last column. A single word longer than the width
+// overflows the line, unless it is broken on a breakpoint or a hyphenation
+// point.
+//
+// If width is 0, lines are not wrapped: the text is written as is and only the
+// prefix is added to the start of each line after a newline.
 func New(w io.Writer, width uint) *Writer {
-	return &Writer{
-		writer: w,
-		width:  int(width),
+	var writer = &Writer{
+		dst:   destination{writer: w},
+		width: int(width),
+	}
+	writer.writer = &writer.dst
+	return writer
+}
+
+// NewWithOptions returns a new Writer like New, configured with the options.
+func NewWithOptions(w io.Writer, width uint, opts ...Option) *Writer {
+	var writer = New(w, width)
+	for _, opt := range opts {
+		opt(writer)
+	}
+	return writer
+}
+
+// destination writes to the underlying io.Writer, retrying short writes, and
+// keeps the first write error.
+type destination struct {
+	writer    io.Writer // underlying writer
+	err       error     // first write error
+	count     int       // number of bytes written
+	limit     int       // maximum number of bytes to write
+	ellipsis  string    // marker of the truncated output
+	held      []byte    // bytes held back for the ellipsis
+	truncated bool      // the output is truncated
+}
+
+func (d *destination) Write(b []byte) (n int, err error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if d.truncated {
+		return len(b), nil // the rest of the output is dropped
+	}
+	if d.limit < 1 ||
+		(len(d.held) == 0 && d.count+len(b) <= d.limit-len(d.ellipsis)) {
+		return d.write(b)
+	}
+	data := append(d.held, b...)
+	if d.count+len(data) > d.limit {
+		d.truncated = true
+		d.held = nil
+		size := runeCut(data, d.limit-d.count-len(d.ellipsis))
+		if _, err = d.write(data[:size]); err != nil {
+			return 0, err
+		}
+		if d.count+len(d.ellipsis) <= d.limit {
+			if _, err = d.write([]byte(d.ellipsis)); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+	// hold back the bytes that may be replaced by the ellipsis
+	size := runeCut(data, d.limit-d.count-len(d.ellipsis))
+	d.held = data[size:]
+	if _, err = d.write(data[:size]); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// WriteString writes s to the underlying writer without copying it, if the
+// output is not limited and the writer implements io.StringWriter.
+func (d *destination) WriteString(s string) (n int, err error) {
+	sw, ok := d.writer.(io.StringWriter)
+	if !ok || d.limit > 0 || d.err != nil {
+		return d.Write([]byte(s))
 	}
+	for n < len(s) {
+		var size int
+		size, err = sw.WriteString(s[n:])
+		n += size
+		d.count += size
+		if err == nil && size == 0 {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			d.err = err
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// flush writes the bytes held back for the ellipsis.
+func (d *destination) flush() error {
+	if len(d.held) == 0 || d.err != nil {
+		return d.err
+	}
+	_, err := d.write(d.held)
+	d.held = nil
+	return err
+}
+
+// runeCut returns the length of the longest prefix of b that is not longer
+// than n and does not end in the middle of a rune.
+func runeCut(b []byte, n int) int {
+	if n <= 0 {
+		return 0
+	}
+	if n >= len(b) {
+		return len(b)
+	}
+	for n > 0 && !utf8.RuneStart(b[n]) {
+		n--
+	}
+	return n
+}
+
+// write writes b to the underlying writer, retrying short writes.
+func (d *destination) write(b []byte) (n int, err error) {
+	for n < len(b) {
+		var size int
+		size, err = d.writer.Write(b[n:])
+		n += size
+		d.count += size
+		if err == nil && size == 0 {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			d.err = err
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// NewBuffer returns a new Writer that accumulates wrapped text in an internal
+// buffer. The text can be drained with WriteTo or with io.Copy from Reader.
+func NewBuffer(width uint) *Writer {
+	var buf = new(bytes.Buffer)
+	var w = New(buf, width)
+	w.buf = buf
+	return w
+}
+
+// Reset discards any buffered data, the current line state and the write
+// error, so the Writer can be reused. The configuration and the underlying
+// io.Writer are kept.
+func (w *Writer) Reset() {
+	w.pos = 0
+	w.lineStart = 0
+	w.space.Reset()
+	w.word.Reset()
+	w.wordLen = 0
+	w.breaks = w.breaks[:0]
+	w.newLine = false
+	w.wrapped = false
+	w.split = wordBreak{}
+	w.ansi = false
+	w.closed = false
+	w.started = false
+	w.textStart = 0
+	w.line.Reset()
+	w.stats = Stats{}
+	w.dst.err = nil
+	if w.widthPending {
+		w.width, w.widthPending = w.nextWidth, false
+	}
+	w.dst.count = 0
+	w.consumed = 0
+	w.dst.held = nil
+	w.dst.truncated = false
+	w.breakList = w.breakList[:0]
+	w.quoting = w.quoteMarker != ""
+	w.quoteRun.Reset()
+	w.quoteMatch = 0
+	w.quote, w.quoteLen = "", 0
+	w.newlines = 0
+	w.invalidRun = false
+	w.row.Reset()
+	w.inLine = false
+	w.lineNum = 0
+	w.trimmed = false
+	if w.buf != nil {
+		w.buf.Reset()
+	}
+}
+
+// SetWidth sets the width of the lines. It applies from the next written rune:
+// the text already written to the current line is not wrapped again, so the
+// change of the width is clean only at the start of the line, for example right
+// after a newline. Use SetWidthForNext to apply the width from the next line.
+// If the width is 0, lines are not wrapped.
+func (w *Writer) SetWidth(width uint) {
+	w.width = int(width)
+	w.widthPending = false
+}
+
+// SetWidthForNext sets the width for the lines starting after the next line
+// break, a newline of the source text or a break inserted by wrapping, so the
+// width can be changed between paragraphs without affecting the buffered word
+// or the current line. If nothing is written to the current line yet, the
+// width applies at once.
+func (w *Writer) SetWidthForNext(width uint) {
+	if w.AtLineStart() {
+		w.SetWidth(width)
+		return
+	}
+	w.nextWidth, w.widthPending = int(width), true
+}
+
+// AtLineStart reports whether the next written text begins a new line: nothing
+// is written yet or the last line is ended by a newline, and no word, spaces or
+// line content is buffered. A newline held in fill mode is not a line start,
+// because the next text may be joined to the line.
+func (w *Writer) AtLineStart() bool {
+	return (!w.started || w.newLine) && w.word.Len() == 0 &&
+		w.space.Len() == 0 && w.line.Len() == 0 && w.row.Len() == 0 &&
+		w.quoteRun.Len() == 0 && w.newlines != 1
 }
 
 // SetTabWidth sets the width of tab characters.
 //
 // Writer attempts to handle tab characters gracefully, converting them to
 // spaces aligned on the boundary. If width is 0, when used tab character as is
-// by default.
+// by default: it counts as one column and, like a space, is stripped at the
+// end of the line and at a line break inserted by wrapping.
 func (w *Writer) SetTabWidth(width int) {
 	w.tabWidh = width
 }
 
+// SetTabAsSpace defines whether the tab characters are written as a single
+// space when the tab width is 0. If the line width is 0 too, only the tabs are
+// replaced and the rest of the text is written as is.
+func (w *Writer) SetTabAsSpace(b bool) {
+	w.tabSpace = b
+}
+
 // SetPrefix add prefix for writing on start of newline. The prefix does not
 // affect the first line.
+//
+// By default the prefix counts toward the line width (see
+// SetPrefixCountsTowardWidth). If the prefix is as long as the width or
+// longer, Writer does not fail: every wrapped line holds the prefix followed by
+// a single word. Use Validate to detect such configuration.
 func (w *Writer) SetPrefix(s string) {
+	w.prefixFunc = nil
 	w.prefix = s
-	w.prefixLen = utf8.RuneCountInString(s)
+	w.prefixLen = w.measure(s)
+}
+
+// SetPrefixFunc sets the function that returns the prefix of each line by its
+// zero-based number, including the first line. The function is called when the
+// line is started and the length of the returned prefix is measured for that
+// line only. It replaces the prefixes set with SetPrefix and
+// SetFirstLinePrefix; SetPrefix is the same as the function returning the
+// constant prefix for all lines but the first one.
+func (w *Writer) SetPrefixFunc(f func(lineNum int) string) {
+	w.prefixFunc = f
+	if f != nil && w.lineNum > 0 {
+		w.prefix = f(w.lineNum)
+		w.prefixLen = w.measure(w.prefix)
+	}
+}
+
+// SetPrefixCountsTowardWidth defines whether the prefix reduces the width
+// available for the line content. It is true by default. When set to false the
+// prefix is written as a gutter outside of the text column and every wrapped
+// line may hold the full width of content.
+func (w *Writer) SetPrefixCountsTowardWidth(b bool) {
+	w.prefixFree = !b
+}
+
+// SetFirstLinePrefix add prefix for writing on start of the first line. It is
+// written before the first written rune and counts toward the line width like
+// the prefix set with SetPrefix.
+func (w *Writer) SetFirstLinePrefix(s string) {
+	w.first = s
+	w.firstLen = w.measure(s)
+}
+
+// SetHangingBullet sets the marker as the first line prefix and the same
+// width run of spaces as the prefix for continuation lines, so the wrapped list
+// item text is aligned after the marker.
+func (w *Writer) SetHangingBullet(marker string) {
+	w.SetFirstLinePrefix(marker)
+	w.SetPrefix(strings.Repeat(" ", w.firstLen))
+}
+
+// SetMargin sets the left margin of n spaces written at the start of every
+// line, including the first one, before the prefixes. The margin always counts
+// toward the width, so it reduces the width available for the content.
+func (w *Writer) SetMargin(n int) {
+	if n < 0 {
+		n = 0
+	}
+	w.margin = n
+}
+
+// SetHangingIndent sets the indent of n spaces for the lines continuing a
+// wrapped paragraph, so the first line of each paragraph is flush left and the
+// rest is indented, as in reference lists. The indent is written after the
+// prefix and always counts toward the width. A paragraph short enough not to
+// wrap gets no indent.
+func (w *Writer) SetHangingIndent(n int) {
+	if n < 0 {
+		n = 0
+	}
+	w.hanging = n
+}
+
+// SetTransform sets the function called for the content of each completed
+// line, without the prefix and the newline. The returned string is written
+// instead of the line content.
+//
+// The transformation runs after all wrapping decisions are made, so it may
+// change the length of the line, for example to add color escape sequences,
+// without affecting the wrapping of the following lines. The last line is
+// completed only by Flush or Close, so call one of them at the end of the text.
+func (w *Writer) SetTransform(f func(line string) string) {
+	w.transform = f
+}
+
+// SetDirection sets the paragraph direction. Right-to-left lines are aligned
+// to the right edge of the width, for terminals that do not support
+// bidirectional text. The leading and trailing whitespace of such lines is
+// stripped, so that it does not break the alignment. The prefix is still
+// written at the start of the line.
+//
+// The trailing punctuation of the line, such as the final period, is moved to
+// the visual start of the line, the left edge, where a bidirectional terminal
+// would show it. Otherwise the text is written in logical order: runs are not
+// reordered and brackets are not mirrored. Lines are aligned only when the
+// width is set.
+// The last line is completed only by Flush or Close, so call one of them at
+// the end of the text.
+func (w *Writer) SetDirection(d Direction) {
+	w.rtl = d == RTL
+}
+
+// SetHyphenator sets the function that returns the rune offsets within a word
+// where it may be hyphenated. When a word does not fit the line, it is broken
+// on the last hyphenation point that fits, with a hyphen added to the end of
+// the line. If no hyphenation point fits, the word is wrapped as usual,
+// including the word breaks on the breakpoints, the case changes and the
+// punctuation.
+func (w *Writer) SetHyphenator(f func(word string) []int) {
+	w.hyphenate = f
+}
+
+// SetStripBOM defines whether the byte order mark (U+FEFF) at the start of the
+// text is stripped. By default it is written as is. The byte order mark never
+// counts toward the line width. In the middle of the text U+FEFF is a zero
+// width no-break space: it is written as a part of the word and has no width.
+func (w *Writer) SetStripBOM(b bool) {
+	w.stripBOM = b
+}
+
+// SetKeepExtraSpaces defines how the spaces are handled at the line break
+// inserted by wrapping. The first space at the break is always dropped. By
+// default the rest of the spaces is dropped too. If b is true, they are carried
+// to the start of the next line after the prefix, so the double space after a
+// sentence becomes a single leading space. The spaces before a newline of the
+// source text are not affected (see SetKeepPreNewlineSpace).
+func (w *Writer) SetKeepExtraSpaces(b bool) {
+	w.extraSpaces = b
+}
+
+// SetTrimLeadingSpace defines whether the leading whitespace of the text,
+// including the leading newlines, is skipped up to the first visible rune. The
+// whitespace may span several writes. The blank lines inside the text are not
+// affected. The text starts again after Reset.
+func (w *Writer) SetTrimLeadingSpace(b bool) {
+	w.trimLead = b
+}
+
+// SetKeepPreNewlineSpace defines how the spaces before a newline of the source
+// text are handled. By default they are stripped as any trailing whitespace.
+// If b is true, they are always written before the newline, even if they
+// exceed the width. If the width is 0, the text is written as is.
+func (w *Writer) SetKeepPreNewlineSpace(b bool) {
+	w.nlSpace = b
+}
+
+// SetCollectStats enables collecting of the wrapping statistics, returned by
+// Stats. The lines are counted if the width is 0 too, but then the text is
+// not written as is: the trailing whitespace of the lines is stripped.
+func (w *Writer) SetCollectStats(b bool) {
+	w.withStats = b
+}
+
+// SetMaxBytes limits the output to n bytes written to the underlying
+// io.Writer, including the prefixes and the newlines. The output is truncated
+// on the rune boundary, with the ellipsis set by SetEllipsis, and the rest of
+// the text is consumed silently. If n is 0, the output is not limited.
+func (w *Writer) SetMaxBytes(n int) {
+	if n < 0 {
+		n = 0
+	}
+	w.dst.limit = n
+}
+
+// SetEllipsis sets the marker written at the end of the truncated output. It
+// counts toward the limit set by SetMaxBytes. The output that may be replaced
+// by the ellipsis is held back until it is known whether the output is
+// truncated, so call Flush or Close at the end of the text.
+func (w *Writer) SetEllipsis(s string) {
+	w.dst.ellipsis = s
+}
+
+// Truncated reports whether the output is truncated by the limit set with
+// SetMaxBytes.
+func (w *Writer) Truncated() bool {
+	return w.dst.truncated
+}
+
+// Break describes the line break of the output.
+type Break struct {
+	Offset   int  // offset of the newline in the output
+	Inserted bool // the break is inserted by wrapping
+}
+
+// SetCollectBreaks enables collecting of the line breaks, returned by Breaks.
+func (w *Writer) SetCollectBreaks(b bool) {
+	w.withBreaks = b
+}
+
+// Breaks returns the line breaks written since the collecting was enabled or
+// the Writer was reset: the newlines of the source text and the breaks
+// inserted by wrapping. The offsets are counted in bytes of the output written
+// to the underlying io.Writer since it was created or reset.
+func (w *Writer) Breaks() []Break {
+	return w.breakList
+}
+
+// Stats returns the wrapping statistics of the text written since the
+// statistics collecting was enabled or the Writer was reset. The line length
+// includes the prefix if it counts toward the width, but not the stripped
+// trailing whitespace. The current line is included if it is not empty.
+func (w *Writer) Stats() Stats {
+	var stats = w.stats
+	if w.withStats && (w.pos > w.lineStart || w.wordLen > 0) {
+		stats.add(w.pos+w.wordLen, w.width)
+	}
+	return stats
+}
+
+// SetContinuationMarker sets the marker written at the end of each line ended
+// by a break inserted by wrapping, but not at the end of the lines ended by the
+// newlines of the source text or of the last line. The length of the marker is
+// reserved on every line, so the content with the marker fits the width.
+func (w *Writer) SetContinuationMarker(s string) {
+	w.marker = s
+	w.markerLen = w.measure(s)
+}
+
+// SetSoftBreak sets the sequence written instead of the newline at the line
+// breaks inserted by wrapping. The newlines of the source text are written as
+// is. The space at the break is dropped as usual, so the sequence of a single
+// space joins the wrapped lines back for the consumer that wraps the text
+// again. The prefix of the next line is still written. SetSoftBreak("\n")
+// restores the default.
+func (w *Writer) SetSoftBreak(s string) {
+	w.softBreak = append([]byte{}, s...)
+}
+
+// SetFinalNewline defines whether Flush and Close end the text with exactly
+// one newline, if it does not already end with a newline. It is disabled by
+// default. Nothing is added if no text is written, including the text of only
+// whitespace or the byte order mark.
+func (w *Writer) SetFinalNewline(b bool) {
+	w.finalNewLine = b
+}
+
+// SetKeepFinalSpace defines whether the trailing spaces at the end of the
+// written text are kept, for example after a prompt before the cursor. If b is
+// true, the pending spaces are written by Flush and Close, when they fit the
+// line. Write holds them as usual, so the trailing spaces of the other lines
+// are still stripped, wherever the text is split between writes.
+func (w *Writer) SetKeepFinalSpace(b bool) {
+	w.finalSpace = b
+}
+
+// SetWidthProfile sets the profile used to measure the display width of the
+// text and sets the width unit to UnitDisplay. By default every rune occupies
+// one column (see ASCII). The zero width joiner (U+200D) occupies no columns
+// with any profile.
+func (w *Writer) SetWidthProfile(p WidthProfile) {
+	w.profile = p
+	w.SetWidthUnit(UnitDisplay)
+}
+
+// SetWidthUnit sets the unit of the width, the prefix length and the line
+// position. By default it is UnitRunes.
+//
+// With UnitBytes the length of the line including the prefix never exceeds the
+// width in bytes of the UTF-8 encoding, unless a single word is longer than
+// the width. A multi-byte rune is never split: if it does not fit the line, it
+// is moved to the next line with the word, so the line may be a few bytes
+// shorter than the width.
+func (w *Writer) SetWidthUnit(u Unit) {
+	w.unit = u
+	w.prefixLen = w.measure(w.prefix)
+	w.firstLen = w.measure(w.first)
+	w.markerLen = w.measure(w.marker)
+}
+
+// SetVerbatim enables the verbatim mode for pre-formatted text. In this mode
+// lines are folded at exactly the width columns, regardless of the word
+// boundaries, and all whitespace is kept as is. Tabs are still expanded if the
+// tab width is set.
+//
+// The verbatim mode and the word wrapping options, such as breakpoints,
+// punctuation breaks or hyphenation, are mutually exclusive: the latter are
+// ignored in the verbatim mode.
+func (w *Writer) SetVerbatim(b bool) {
+	w.verbatim = b
+}
+
+// SetFill enables the fill mode for the text with the soft-wrapped lines. A
+// single newline of the source text is written as a space, so the lines of a
+// paragraph are joined and wrapped again, and a blank line is a paragraph
+// break. Consecutive blank lines are preserved. The prefix is written after the
+// paragraph breaks and the line breaks inserted by wrapping.
+//
+// The newline is held until the next rune to decide whether it is a paragraph
+// break, so the newline at the end of Write is written only by the following
+// Write or by Flush. Flush writes a held newline as a line break.
+func (w *Writer) SetFill(b bool) {
+	w.fill = b
+}
+
+// SetPreserveTableRows enables writing of the table rows as is. A source line
+// that consists only of the table drawing runes, such as "+", "-", "=", "|",
+// ":" and the box drawing characters, and whitespace is not wrapped. The
+// detection is made for each source line, so the prose lines around the table
+// are wrapped as usual.
+func (w *Writer) SetPreserveTableRows(b bool) {
+	w.tableRows = b
+}
+
+// SetQuotePrefix sets the quote marker, for example "> ", to reflow the quoted
+// text of email replies. The leading run of quote markers, possibly nested like
+// "> > " or ">> ", is detected on each source line. It is excluded from
+// wrapping and written at the start of every resulting line, including the
+// wrapped ones, after the prefix. The trailing spaces of the marker are
+// optional in the text. An empty marker disables the detection.
+func (w *Writer) SetQuotePrefix(marker string) {
+	w.quoteMarker = strings.TrimRight(marker, " ")
+	w.quoting = w.quoteMarker != ""
 }
 
 // GetPrefix return the current Writer prefix.
@@ -76,17 +811,179 @@ func (w *Writer) GetPrefix() string {
 }
 
 // SetBreakpoints set additional word breakpoint runes. For exaple: "-:^".
+//
+// A word may be broken after a breakpoint rune, that stays at the end of the
+// line. When the word does not fit the line, it is broken on the breakpoint
+// with the highest priority (see SetBreakpointPriority) that fits the line, or
+// on the last one if priorities are equal.
+//
+// Whitespace and newlines always end the word, so they can not be breakpoints:
+// such runes are ignored and reported by Validate. The duplicated runes are
+// ignored too. Breakpoints take precedence over the punctuation breaks set
+// with SetPunctuationBreaks. The word is never broken next to the zero width
+// joiner (U+200D), that joins the runes around it.
 func (w *Writer) SetBreakpoints(s string) {
-	w.breakpoints = bytes.Runes([]byte(s))
+	w.breakpoints, w.wsBreaks = breakpointRunes(s)
+}
+
+// SetLeadingBreakpoints sets the breakpoint runes, such as an em dash or an
+// opening quote, that lead the next line: the word may be broken before such
+// rune, so it is written at the start of the next line after the prefix. The
+// priority of the breakpoints applies to them as well. Whitespace runes are
+// ignored.
+func (w *Writer) SetLeadingBreakpoints(s string) {
+	w.leading, _ = breakpointRunes(s)
+}
+
+// SetInvalidUTF8 sets the handling of the invalid UTF-8 bytes of the text. By
+// default each invalid byte is replaced with the replacement character U+FFFD,
+// that is one column wide. The invalid bytes are a part of the word, they never
+// break it. The mode applies to the verbatim text as well. If the width is 0,
+// the text is written as is.
+func (w *Writer) SetInvalidUTF8(mode InvalidUTF8) {
+	w.invalid = mode
+}
+
+// SetObjectWidth registers the marker of the embedded object, such as an
+// image placeholder, that occupies cols columns of the line. Each occurrence
+// of the marker is written as is and is never split, but counts as cols
+// columns for wrapping. The marker must be written with a single Write call.
+// Setting the width for the same marker again replaces it.
+func (w *Writer) SetObjectWidth(marker string, cols int) {
+	if marker == "" {
+		return
+	}
+	if cols < 0 {
+		cols = 0
+	}
+	for i, o := range w.objects {
+		if o.marker == marker {
+			w.objects[i].width = cols
+			return
+		}
+	}
+	w.objects = append(w.objects, object{marker: marker, width: cols})
+}
+
+// SetInvisibleBreakpoints sets the runes that mark the word break positions
+// without being written, for example "|" inserted by hand. The word may be
+// broken at such rune as after a breakpoint, but the rune itself is always
+// dropped from the output and has no width. Verbatim text is written as is.
+// Whitespace runes are ignored and reported by Validate, as with
+// SetBreakpoints.
+func (w *Writer) SetInvisibleBreakpoints(s string) {
+	w.invisible, w.wsInvisible = breakpointRunes(s)
+}
+
+// SetPreferBreakpoints defines whether the breakpoints are preferred over the
+// spaces to break the lines. If b is true, the line that would overflow is
+// broken after the last breakpoint written to it, and the text after the
+// breakpoint is carried to the next line. The line is broken on the space as
+// usual if it has no breakpoints. The last line is completed only by Flush or
+// Close, so call one of them at the end of the text.
+func (w *Writer) SetPreferBreakpoints(b bool) {
+	w.preferBreaks = b
+}
+
+// SetBreakpointPriority sets the order of preference for the breakpoints:
+// runes earlier in s are preferred over the later ones and over the
+// breakpoints not listed in s. For example, with "/-" the word is broken after
+// the slash rather than after the hyphen, if both fit the line.
+//
+// The priority does not allow a word that does not fit the line to overflow
+// it: a preferred breakpoint is used only if it fits.
+func (w *Writer) SetBreakpointPriority(s string) {
+	w.priority = []rune(s)
+}
+
+// Validate reports a configuration that can not produce sensible output. It
+// returns ErrNoContentWidth if the first line prefix with the margin, or the
+// prefix with the margin and the hanging indent, is not shorter than the width
+// and ErrIgnoredBreakpoints if some of the breakpoints or of the invisible
+// breakpoints are ignored. The prefix function, if it is set, is checked by the
+// prefixes it returns for the first two lines.
+func (w *Writer) Validate() error {
+	first, prefix := w.firstLen, w.prefixLen
+	if w.prefixFunc != nil {
+		first, prefix = w.measure(w.prefixFunc(0)), w.measure(w.prefixFunc(1))
+	}
+	if w.prefixFree {
+		first, prefix = 0, 0
+	}
+	if w.width > 0 && (w.margin+first >= w.lineWidth() ||
+		w.margin+w.hanging+prefix >= w.lineWidth()) {
+		return ErrNoContentWidth
+	}
+	if w.wsBreaks || w.wsInvisible {
+		return ErrIgnoredBreakpoints
+	}
+	return nil
+}
+
+// SetBreakOnCase enables breaking of words that are too long to fit the line
+// on camelCase humps and after underscores. The lowercase run stays on the
+// current line and the uppercase segment starts the next one. Words that fit
+// the line are never broken.
+func (w *Writer) SetBreakOnCase(b bool) {
+	w.caseBreak = b
+}
+
+// SetPunctuationBreaks set punctuation runes after which a word may be
+// broken. For example: ".,;:".
+//
+// When the word does not fit the line, it is broken after the last punctuation
+// rune that fits, so the punctuation stays at the end of the line and the rest
+// of the word starts the next one. Unlike breakpoints, punctuation breaks are
+// used only if there are no breakpoints that fit the line.
+func (w *Writer) SetPunctuationBreaks(s string) {
+	w.punct = []rune(s)
+}
+
+// wordBreak is a position in the word where it may be broken.
+type wordBreak struct {
+	size     int  // offset in bytes
+	length   int  // offset in columns
+	priority int  // preference of the break
+	fit      bool // the break is allowed for word that fits the line
+}
+
+// markBreak remembers the word break position before rune c, if the lexer
+// allows to break the word there.
+func (w *Writer) markBreak(c rune) {
+	last, _ := utf8.DecodeLastRune(w.word.Bytes())
+	if priority, fit, ok := w.breakBefore(last, c); ok {
+		w.breaks = append(w.breaks, wordBreak{size: w.word.Len(),
+			length: w.wordLen, priority: priority, fit: fit})
+	}
 }
 
-func (w *Writer) isBreakpoint(c rune) bool {
-	for _, r := range w.breakpoints {
-		if r == c {
-			return true
+// wordBreak returns the index of the best word break position for the word
+// that does not fit the line or -1. It is the break with the highest priority
+// that fits the line. If none of them fits and the line is empty, it is the
+// first break.
+func (w *Writer) wordBreak() int {
+	var best = -1
+	for i, b := range w.breaks {
+		if !b.fit && w.wordLen <= w.lineWidth() {
+			continue // the word fits the empty line
+		}
+		if w.column()+w.space.Len()+b.length > w.lineWidth() {
+			if best < 0 && w.lineEmpty() {
+				best = i
+			}
+			break
+		}
+		if best < 0 || b.priority >= w.breaks[best].priority {
+			best = i
 		}
 	}
-	return false
+	return best
+}
+
+// lineEmpty reports whether nothing but the prefix is written on the current
+// line.
+func (w *Writer) lineEmpty() bool {
+	return w.pos <= w.lineStart && w.space.Len() == 0
 }
 
 // SetPosition set current line position for correct word wrapping.
@@ -95,125 +992,1070 @@ func (w *Writer) SetPosition(p int) {
 	w.pos = p
 }
 
+// writeFinalSpace writes the pending spaces at the end of the written text if
+// they are kept and fit the line.
+func (w *Writer) writeFinalSpace() error {
+	if !w.finalSpace || w.space.Len() == 0 ||
+		(w.width > 0 && w.column()+w.space.Len() > w.lineWidth()) {
+		return nil
+	}
+	if err := w.writePrefix(); err != nil {
+		return err
+	}
+	return w.writeSpaces()
+}
+
 func (w *Writer) writeSpaces() error {
 	w.pos += w.space.Len()
-	_, err := w.space.WriteTo(w.writer)
+	_, err := w.space.WriteTo(w.output())
+	return err
+}
+
+func (w *Writer) writeFirstPrefix() error {
+	w.started = true
+	first, firstLen := w.first, w.firstLen
+	if w.prefixFunc != nil {
+		first = w.prefixFunc(0)
+		firstLen = w.measure(first)
+	}
+	if firstLen < 1 && w.margin < 1 {
+		return nil
+	}
+	if !w.prefixFree {
+		w.pos += firstLen
+	}
+	w.pos += w.margin
+	w.lineStart = w.pos
+	_, err := io.WriteString(w.writer, strings.Repeat(" ", w.margin)+first)
 	return err
 }
 
+// nextLine starts counting the next line and gets its prefix from the prefix
+// function, if it is set.
+func (w *Writer) nextLine() {
+	w.lineNum++
+	if w.prefixFunc != nil {
+		w.prefix = w.prefixFunc(w.lineNum)
+		w.prefixLen = w.measure(w.prefix)
+	}
+}
+
 func (w *Writer) writePrefix() error {
-	if !w.newLine || w.prefixLen < 1 {
+	if !w.newLine {
 		return nil
 	}
 	w.newLine = false
-	w.pos += w.prefixLen
-	_, err := io.WriteString(w.writer, w.prefix)
+	indent := w.indent()
+	if w.prefixLen < 1 && w.quoteLen < 1 && indent < 1 && w.margin < 1 {
+		return nil
+	}
+	if !w.prefixFree {
+		w.pos += w.prefixLen + w.quoteLen
+	}
+	w.pos += w.margin + indent
+	w.lineStart = w.pos
+	_, err := io.WriteString(w.writer, strings.Repeat(" ", w.margin)+
+		w.prefix+w.quote+strings.Repeat(" ", indent))
 	return err
 }
 
+// indent returns the hanging indent of the pending line.
+func (w *Writer) indent() int {
+	if w.wrapped {
+		return w.hanging
+	}
+	return 0
+}
+
 func (w *Writer) writeWord() error {
 	if w.word.Len() == 0 {
 		return nil
 	}
+	if w.hyphenate != nil && w.width > 0 {
+		if err := w.hyphenateWord(); err != nil {
+			return err
+		}
+	}
+	return w.putWord()
+}
+
+// putWord writes the word with the prefix and the preceding spaces.
+func (w *Writer) putWord() error {
 	if err := w.writePrefix(); err != nil {
 		return err
 	}
 	if err := w.writeSpaces(); err != nil {
 		return err
 	}
-	_, err := w.word.WriteTo(w.writer)
+	if w.preferBreaks {
+		// remember the last breakpoint of the line
+		for _, b := range w.breaks {
+			if b.fit && b.priority >= 0 {
+				w.split = wordBreak{size: w.line.Len() + b.size,
+					length: w.pos + b.length}
+				w.splitEnd = w.wordEnd - w.word.Len() + b.size
+			}
+		}
+	}
+	w.lineEnd = w.wordEnd
+	_, err := w.word.WriteTo(w.output())
 	w.pos += w.wordLen
 	w.wordLen = 0
+	w.breaks = w.breaks[:0]
 	return err
 }
 
-func (w *Writer) writeNewLine() error {
-	if err := w.writePrefix(); err != nil {
-		return err
+// writeWordPart writes the first size bytes of the word, that are length
+// columns wide, and keeps the rest of the word buffered.
+func (w *Writer) writeWordPart(size, length int) error {
+	rest := append([]byte(nil), w.word.Bytes()[size:]...)
+	restLen := w.wordLen - length
+	var breaks []wordBreak
+	for _, b := range w.breaks {
+		if b.size > size {
+			b.size -= size
+			b.length -= length
+			breaks = append(breaks, b)
+		}
 	}
-	w.newLine = true
-	w.pos = 0
-	w.space.Reset()
-	_, err := w.writer.Write([]byte{'\n'})
+	w.word.Truncate(size)
+	w.wordLen = length
+	end := w.wordEnd
+	w.wordEnd -= len(rest)
+	err := w.putWord()
+	w.wordEnd = end
+	w.word.Write(rest)
+	w.wordLen = restLen
+	w.breaks = append(w.breaks, breaks...)
 	return err
 }
 
-// Write wraps UTF-8 encoded text at word boundaries when lines exceed a limit
-// number of characters. Newlines are preserved, including consecutive and
-// trailing newlines, though trailing whitespace is stripped from each line.
-//
-// It returns the number of bytes written and any write error encountered.
-func (w *Writer) Write(b []byte) (n int, err error) {
-	if w.width < 1 && w.prefix == "" {
-		return w.writer.Write(b) // no wrap
+// writeBreak writes the line break inserted by wrapping. The first of the
+// spaces at the break is dropped and the rest is carried to the next line if
+// the extra spaces are kept.
+func (w *Writer) writeBreak() error {
+	var extra []byte
+	if w.extraSpaces && w.space.Len() > 1 {
+		_, size := utf8.DecodeRune(w.space.Bytes())
+		extra = append(extra, w.space.Bytes()[size:]...)
+	}
+	err := w.endLine(true)
+	w.space.Write(extra)
+	return err
+}
+
+// splitLine breaks the buffered line after the last breakpoint and carries the
+// rest of the line to the next line.
+func (w *Writer) splitLine() error {
+	tail := append([]byte(nil), w.line.Bytes()[w.split.size:]...)
+	tailLen := w.pos - w.split.length
+	space := append([]byte(nil), w.space.Bytes()...)
+	w.line.Truncate(w.split.size)
+	w.pos = w.split.length
+	end := w.lineEnd
+	w.lineEnd = w.splitEnd
+	if err := w.endLine(true); err != nil {
+		return err
+	}
+	w.lineEnd = end
+	if err := w.writePrefix(); err != nil {
+		return err
+	}
+	w.line.Write(tail)
+	w.pos += tailLen
+	w.space.Write(space)
+	return nil
+}
+
+// lineWidth returns the width available for the line content and the
+// prefixes, reserving the continuation marker.
+func (w *Writer) lineWidth() int {
+	return w.width - w.markerLen
+}
+
+// column returns the current line position including the pending prefix.
+func (w *Writer) column() int {
+	if !w.newLine {
+		return w.pos
+	}
+	if w.prefixFree {
+		return w.pos + w.margin + w.indent()
+	}
+	return w.pos + w.margin + w.prefixLen + w.quoteLen + w.indent()
+}
+
+// hyphenateWord writes the parts of the word that does not fit the line,
+// broken on the hyphenation points with a hyphen added, and keeps the rest of
+// the word buffered. If no hyphenation point fits, the word is broken on the
+// word break position or moved to the next line, unless the line is empty.
+func (w *Writer) hyphenateWord() error {
+	if w.column()+w.space.Len()+w.wordLen <= w.lineWidth() {
+		return nil
+	}
+	word := w.word.Bytes()
+	var points []wordBreak
+	for _, p := range w.hyphenate(w.word.String()) {
+		size := runeOffset(word, p)
+		if isJoined(word, size) {
+			continue
+		}
+		points = append(points, wordBreak{size: size, length: w.textWidth(word[:size])})
+	}
+	var done wordBreak // the part of the word already written
+	for w.column()+w.space.Len()+w.wordLen > w.lineWidth() {
+		var best = -1 // the last hyphenation point that fits the line
+		for i, p := range points {
+			size, length := p.size-done.size, p.length-done.length
+			if size > 0 && size < w.word.Len() &&
+				w.column()+w.space.Len()+length+1 <= w.lineWidth() &&
+				(best < 0 || p.size > points[best].size) {
+				best = i
+			}
+		}
+		var brk = -1 // the word break, if no hyphenation point fits
+		if best < 0 {
+			brk = w.wordBreak()
+		}
+		switch {
+		case best >= 0:
+			p := points[best]
+			if err := w.writeWordPart(p.size-done.size, p.length-done.length); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w.output(), "-"); err != nil {
+				return err
+			}
+			w.pos++
+			done = p
+		case brk >= 0:
+			// break the word on the best word break position
+			b := w.breaks[brk]
+			if err := w.writeWordPart(b.size, b.length); err != nil {
+				return err
+			}
+			done.size += b.size
+			done.length += b.length
+		case !w.lineEmpty():
+			// move the word to the next line
+		default:
+			return nil // too long word
+		}
+		if err := w.writeBreak(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runeOffset returns the byte offset of the n-th rune in b.
+func runeOffset(b []byte, n int) int {
+	var i int
+	for ; n > 0 && i < len(b); n-- {
+		_, size := utf8.DecodeRune(b[i:])
+		i += size
+	}
+	return i
+}
+
+// isJoined reports whether the offset i of b is next to the zero width joiner,
+// so the runes around it are joined.
+func isJoined(b []byte, i int) bool {
+	before, _ := utf8.DecodeLastRune(b[:i])
+	after, _ := utf8.DecodeRune(b[i:])
+	return before == zwj || after == zwj
+}
+
+// writeNewLine completes the line on the newline of the source text.
+func (w *Writer) writeNewLine() error {
+	return w.endLine(false)
+}
+
+// endLine completes the line with a newline, that is inserted by wrapping or
+// comes from the source text.
+func (w *Writer) endLine(inserted bool) error {
+	if err := w.writePrefix(); err != nil {
+		return err
+	}
+	if err := w.writeLine(); err != nil {
+		return err
+	}
+	if inserted && w.marker != "" {
+		if _, err := io.WriteString(w.writer, w.marker); err != nil {
+			return err
+		}
+	}
+	if w.withStats {
+		w.stats.add(w.pos, w.width)
+	}
+	if w.withBreaks {
+		w.breakList = append(w.breakList,
+			Break{Offset: w.dst.count, Inserted: inserted})
+	}
+	if w.withWraps && inserted {
+		w.positions = append(w.positions, w.lineEnd)
+	}
+	w.newLine = true
+	w.nextLine()
+	w.wrapped = inserted
+	w.split = wordBreak{}
+	w.pos = 0
+	w.lineStart = 0
+	w.space.Reset()
+	if w.widthPending {
+		w.width, w.widthPending = w.nextWidth, false
+	}
+	brk := newline
+	if inserted && w.softBreak != nil {
+		brk = w.softBreak
+	}
+	_, err := w.writer.Write(brk)
+	return err
+}
+
+// buffered reports whether the line content is kept in the line buffer until
+// the line is completed.
+func (w *Writer) buffered() bool {
+	return w.transform != nil || w.rtl || w.preferBreaks
+}
+
+// output returns the destination for the line content: the line buffer or the
+// underlying io.Writer.
+func (w *Writer) output() io.Writer {
+	if w.buffered() {
+		return &w.line
+	}
+	return w.writer
+}
+
+// writeLine writes the aligned and transformed content of the line buffer.
+func (w *Writer) writeLine() error {
+	if !w.buffered() {
+		return nil
+	}
+	line := w.line.String()
+	w.line.Reset()
+	var pad int
+	if w.rtl {
+		trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+		width := w.pos - w.measure(line[:len(line)-len(trimmed)])
+		line = leadPunct(strings.TrimRightFunc(trimmed, unicode.IsSpace))
+		if line != "" {
+			pad = w.width - width
+		}
+	}
+	if w.transform != nil {
+		line = w.transform(line)
+	}
+	if pad > 0 {
+		line = strings.Repeat(" ", pad) + line
+	}
+	_, err := io.WriteString(w.writer, line)
+	return err
+}
+
+// leadPunct moves the trailing run of punctuation of the right-to-left line to
+// its start in the visual order.
+func leadPunct(line string) string {
+	var run []rune
+	i := len(line)
+	for i > 0 {
+		c, size := utf8.DecodeLastRuneInString(line[:i])
+		if !unicode.IsPunct(c) {
+			break
+		}
+		run = append(run, c)
+		i -= size
+	}
+	if i == 0 || len(run) == 0 {
+		return line // no punctuation or nothing else
+	}
+	return string(run) + line[:i]
+}
+
+// writeNoWrap writes b as is, only adding the prefix at the start of each line
+// after a newline and replacing the tabs with spaces if it is enabled.
+func (w *Writer) writeNoWrap(b []byte) (n int, err error) {
+	if w.tabSpace && bytes.IndexByte(b, '\t') >= 0 {
+		b = bytes.Replace(b, []byte{'\t'}, []byte{' '}, -1)
+	}
+	if w.prefix == "" && w.prefixFunc == nil && w.margin < 1 {
+		if len(b) > 0 {
+			w.newLine = b[len(b)-1] == '\n'
+		}
+		return w.writer.Write(b)
 	}
-	// read all by runes
 	for len(b) > 0 {
-		c, size := utf8.DecodeRune(b) // current rune
-		b = b[size:]                  // skip rune from source
+		if err = w.writePrefix(); err != nil {
+			return n, err
+		}
+		i := bytes.IndexByte(b, '\n') + 1
+		if i == 0 {
+			i = len(b)
+		}
+		size, err := w.writer.Write(b[:i])
+		n += size
+		if err != nil {
+			return n, err
+		}
+		w.newLine = b[i-1] == '\n'
+		if w.newLine {
+			w.nextLine()
+		}
+		b = b[i:]
+	}
+	return n, nil
+}
+
+// writeVerbatim writes b keeping all whitespace and folding lines when they
+// reach the width.
+func (w *Writer) writeVerbatim(b []byte) (n int, err error) {
+	for i := 0; len(b) > 0 && !w.done(i); i++ {
+		w.lineEnd = w.consumed + n // the break is inserted before the rune
+		c, size := utf8.DecodeRune(b)
+		t := w.scan(c, size, b[0])
+		b = b[size:]
 		n += size
 
 		switch {
-		case c == '\x1B': // ANSI escape sequence
-			w.word.WriteRune(c)
-			w.ansi = true
-		case w.ansi: // in ANSI escape sequence
-			w.word.WriteRune(c)
-			if (c >= 0x40 && c <= 0x5a) || (c >= 0x61 && c <= 0x7a) {
-				// ANSI sequence terminated
-				w.ansi = false
+		case t.kind == lexRaw:
+			err = w.putRaw(t.raw)
+		case t.kind == lexSkip: // the run of invalid bytes is already replaced
+		case t.kind == lexZero: // ANSI escape sequence or U+FEFF
+			err = w.putRune(c, 0)
+		case t.kind == lexNewline:
+			err = w.writeNewLine()
+		case c == '\t' && w.tabWidh > 0:
+			if w.width > 0 && w.column() >= w.lineWidth() && w.pos > w.lineStart {
+				err = w.writeBreak()
 			}
-		case c == '\n': // end of current line
-			// see if we can add the content of the space buffer to the current line
-			if w.word.Len() == 0 {
-				if w.pos+w.space.Len() > w.width {
-					w.pos = 0
-					w.space.Reset()
-				} else {
-					// preserve whitespace
-					w.space.WriteTo(w.writer)
-				}
+			// expand the tab up to the next tab stop or the end of the line
+			col := w.column()
+			spaces := w.tabWidh - col%w.tabWidh
+			if w.width > 0 && col+spaces > w.lineWidth() {
+				spaces = w.lineWidth() - col
 			}
-			w.writeWord()
-			w.writeNewLine()
-		case unicode.IsSpace(c): // end of current word
-			w.writeWord()
-			if c == '\t' && w.tabWidh > 0 {
-				// Replace tabs with spaces while preserving alignment.
-				w.space.Write(bytes.Repeat([]byte{' '}, w.tabWidh-w.pos%w.tabWidh))
-			} else {
-				w.space.WriteRune(c)
+			for ; spaces > 0 && err == nil; spaces-- {
+				err = w.putRune(' ', 1)
 			}
-		case w.isBreakpoint(c): // valid breakpoint
-			// w.writeSpaces()
-			w.writeWord()
-			// encode & write current rune
-			var b = make([]byte, utf8.UTFMax)
-			size := utf8.EncodeRune(b, c)
-			b = b[:size]
-			w.writer.Write(b)
-			w.pos++
-		default: // any other character
-			w.word.WriteRune(c)
-			w.wordLen++
-			// add a line break if the current word would exceed the line's
-			// character limit
-			if w.width > 0 &&
-				w.pos+w.wordLen+w.space.Len() >= w.width &&
-				w.wordLen <= w.width {
-				w.writeNewLine()
+		case c == '\t' && w.tabSpace:
+			err = w.putRune(' ', 1)
+		case c == '\t':
+			err = w.putRune(c, 1)
+		default:
+			err = w.putRune(c, w.runeWidth(c))
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// putRaw writes the invalid UTF-8 byte as is without width.
+func (w *Writer) putRaw(c byte) error {
+	if err := w.writePrefix(); err != nil {
+		return err
+	}
+	_, err := w.output().Write([]byte{c})
+	return err
+}
+
+// putRune writes the rune of a given width, breaking the line before it if it
+// does not fit.
+func (w *Writer) putRune(c rune, width int) error {
+	if w.width > 0 && width > 0 && w.column()+width > w.lineWidth() &&
+		w.pos > w.lineStart {
+		if err := w.writeBreak(); err != nil {
+			return err
+		}
+	}
+	if err := w.writePrefix(); err != nil {
+		return err
+	}
+	var b [utf8.UTFMax]byte
+	_, err := w.output().Write(b[:utf8.EncodeRune(b[:], c)])
+	w.pos += width
+	return err
+}
+
+// Write wraps UTF-8 encoded text at word boundaries when lines exceed a limit
+// number of characters. Newlines are preserved, including consecutive and
+// trailing newlines, though trailing whitespace is stripped from each line.
+//
+// It returns the number of bytes written and any write error encountered.
+// Short writes of the underlying io.Writer are retried. After the first write
+// error all Writer methods return it until Reset is called.
+func (w *Writer) Write(b []byte) (n int, err error) {
+	n, err = w.writeText(b)
+	w.consumed += n
+	return n, err
+}
+
+// contextRunes is the number of runes written between the checks of the
+// context in WriteContext.
+const contextRunes = 4 << 10
+
+// WriteContext writes b as Write does, checking the context every few thousand
+// runes of the text. If the context is done, it stops before the next rune and
+// returns the number of bytes consumed so far and the context error. The
+// Writer stays in the same state as after a Write of the consumed bytes, so
+// the rest of b may be written later to resume the wrapping.
+func (w *Writer) WriteContext(ctx context.Context, b []byte) (n int, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+	w.ctx = ctx
+	n, err = w.Write(b)
+	w.ctx = nil
+	if err == nil && n < len(b) {
+		err = ctx.Err()
+	}
+	return n, err
+}
+
+// done reports whether the context of WriteContext is done before the i-th
+// rune of the written text. The context is checked every contextRunes runes.
+func (w *Writer) done(i int) bool {
+	return w.ctx != nil && i > 0 && i%contextRunes == 0 && w.ctx.Err() != nil
+}
+
+// skipHead begins the text on the first write and returns the size of the head
+// of the written text that is skipped: the byte order mark and the leading
+// whitespace trimmed with SetTrimLeadingSpace. The text of size bytes is
+// decoded with decode from the given offset.
+func (w *Writer) skipHead(size int, decode func(i int) (rune, int)) (n int, err error) {
+	if !w.started && size > 0 {
+		c, runeSize := decode(0)
+		hasBOM := c == '\uFEFF'
+		if hasBOM {
+			n = runeSize
+		}
+		if err = w.begin(hasBOM); err != nil {
+			return 0, err
+		}
+	}
+	if w.trimLead && !w.trimmed {
+		for n < size {
+			c, runeSize := decode(n)
+			if !unicode.IsSpace(c) {
+				break
+			}
+			n += runeSize
+		}
+		w.trimmed = n < size
+	}
+	return n, nil
+}
+
+// begin writes the byte order mark, if the text starts with it and it is not
+// stripped, and the first line prefix.
+func (w *Writer) begin(hasBOM bool) error {
+	if hasBOM && !w.stripBOM {
+		// the byte order mark is not a part of the first line
+		if _, err := w.writer.Write(bom); err != nil {
+			return err
+		}
+	}
+	if err := w.writeFirstPrefix(); err != nil {
+		return err
+	}
+	w.textStart = w.dst.count + len(w.dst.held)
+	return nil
+}
+
+// hasText reports whether any text is written after the byte order mark and
+// the first line prefix, including the line content that is not output yet.
+func (w *Writer) hasText() bool {
+	return w.dst.count+len(w.dst.held) > w.textStart || w.line.Len() > 0
+}
+
+// noWrap reports whether the text is written as is with the prefixes only.
+func (w *Writer) noWrap() bool {
+	return w.width < 1 && !w.buffered() && w.quoteMarker == "" && !w.fill &&
+		len(w.invisible) == 0 && !w.withBreaks && !w.withStats &&
+		!w.widthPending
+}
+
+// writeText writes b without counting the source bytes.
+func (w *Writer) writeText(b []byte) (n int, err error) {
+	if w.dst.err != nil {
+		return 0, w.dst.err
+	}
+	if w.dst.truncated {
+		return len(b), nil
+	}
+	n, err = w.skipHead(len(b), func(i int) (rune, int) {
+		return utf8.DecodeRune(b[i:])
+	})
+	if err != nil {
+		return 0, err
+	}
+	b = b[n:]
+	switch {
+	case w.verbatim:
+		size, err := w.writeVerbatim(b)
+		return n + size, err
+	case w.noWrap():
+		size, err := w.writeNoWrap(b)
+		return n + size, err
+	}
+	// read all by runes
+	for i := 0; len(b) > 0 && w.dst.err == nil && !w.done(i); i++ {
+		w.srcOff = w.consumed + n
+		if len(w.objects) > 0 && !w.ansi && !w.quoting && w.row.Len() == 0 {
+			if size := w.writeObject(b); size > 0 {
+				b = b[size:]
+				n += size
+				w.inLine = true
+				continue
 			}
 		}
+		c, size := utf8.DecodeRune(b) // current rune
+		w.srcEnd = w.srcOff + size
+		w.sourceRune(w.scan(c, size, b[0]))
+		b = b[size:] // skip rune from source
+		n += size
 	}
 	// output last word
 	w.writeWord()
-	return n, err
+	return n, w.dst.err
+}
+
+// sourceRune processes the rune of the source text scanned by the lexer.
+func (w *Writer) sourceRune(t lexeme) {
+	switch t.kind {
+	case lexRaw: // invalid UTF-8 byte
+		w.writeRaw(t.raw)
+		w.inLine = true
+		return
+	case lexSkip:
+		return
+	}
+	switch {
+	case w.quoting:
+		w.quoteRune(t)
+	case w.tableRows && (!w.inLine || w.row.Len() > 0):
+		w.rowRune(t)
+	default:
+		w.textRune(t)
+	}
+	w.inLine = t.c != '\n'
+}
+
+// writeRaw writes the invalid UTF-8 byte as a part of the word without width.
+func (w *Writer) writeRaw(c byte) {
+	if w.quoting {
+		w.endQuote()
+	}
+	if w.row.Len() > 0 {
+		w.replayRow()
+	}
+	if w.fill {
+		w.joinLines()
+	}
+	w.word.WriteByte(c)
+	w.wordEnd = w.srcEnd
+}
+
+// quoteRune collects the quote markers at the start of the line. The first
+// rune that does not continue the markers completes the run.
+func (w *Writer) quoteRune(t lexeme) {
+	c := t.c
+	if w.quoteMatch == 0 && w.quoteRun.Len() > 0 && (c == ' ' || c == '\t') {
+		w.quoteRun.WriteRune(c) // spaces after the marker
+		return
+	}
+	if next, size := utf8.DecodeRuneInString(w.quoteMarker[w.quoteMatch:]); next == c {
+		w.quoteRun.WriteRune(c)
+		w.quoteMatch = (w.quoteMatch + size) % len(w.quoteMarker)
+		return
+	}
+	w.endQuote()
+	w.writeRune(t)
+}
+
+// endQuote completes the run of the quote markers of the current line. The
+// partially matched marker is written as the line content.
+func (w *Writer) endQuote() {
+	run := w.quoteRun.String()
+	part := run[len(run)-w.quoteMatch:]
+	w.quote = run[:len(run)-w.quoteMatch]
+	w.quoteLen = w.measure(w.quote)
+	w.quoteRun.Reset()
+	w.quoteMatch = 0
+	w.quoting = false
+	if !w.newLine && w.quoteLen > 0 {
+		// the first line: the prefix is already written
+		if !w.prefixFree {
+			w.pos += w.quoteLen
+		}
+		w.lineStart = w.pos
+		io.WriteString(w.writer, w.quote)
+	}
+	for _, c := range part {
+		w.writeRune(w.lexRune(c))
+	}
+}
+
+// textRune processes the rune of the source text in the fill mode or as is.
+func (w *Writer) textRune(t lexeme) {
+	if w.fill {
+		w.fillRune(t)
+	} else {
+		w.writeRune(t)
+	}
+}
+
+// isTableRune reports whether the rune is used to draw the table rows.
+func isTableRune(c rune) bool {
+	return strings.ContainsRune("+-=|:", c) || (c >= 0x2500 && c <= 0x259F)
+}
+
+// rowRune collects the source line while it consists of the table drawing
+// runes and whitespace. The completed line is written as is, otherwise the
+// collected runes are processed as the text.
+func (w *Writer) rowRune(t lexeme) {
+	c := t.c
+	if c != '\n' && (isTableRune(c) || unicode.IsSpace(c)) {
+		w.row.WriteRune(c)
+		return
+	}
+	if c == '\n' && w.writeRow() {
+		w.writeNewLine()
+		w.newlines = 2 // the following line is not joined in fill mode
+		return
+	}
+	w.replayRow()
+	w.textRune(t)
+}
+
+// writeRow writes the collected line as is, if it is a table row.
+func (w *Writer) writeRow() bool {
+	row := w.row.String()
+	if strings.IndexFunc(row, isTableRune) < 0 {
+		return false
+	}
+	w.row.Reset()
+	if w.newlines == 1 {
+		// the held newline of the fill mode ends the preceding text
+		w.newlines = 0
+		w.writeRune(w.lexRune('\n'))
+	}
+	w.writePrefix()
+	io.WriteString(w.output(), row)
+	w.pos += w.measure(row)
+	return true
+}
+
+// replayRow processes the collected runes as the text.
+func (w *Writer) replayRow() {
+	row := w.row.String()
+	w.row.Reset()
+	for _, c := range row {
+		w.textRune(w.lexRune(c))
+	}
+}
+
+// fillRune processes the rune of the source text in the fill mode. The newline
+// is held until the next rune: a single newline joins the lines with a space
+// and a blank line is a paragraph break.
+func (w *Writer) fillRune(t lexeme) {
+	if t.c == '\n' {
+		w.newlines++
+		switch {
+		case w.newlines == 2: // paragraph break
+			w.writeRune(t)
+			w.writeRune(t)
+		case w.newlines > 2: // preserve consecutive blank lines
+			w.writeRune(t)
+		}
+		return
+	}
+	if !unicode.IsSpace(t.c) {
+		w.joinLines()
+	}
+	w.newlines = 0
+	w.writeRune(t)
+}
+
+// joinLines joins the lines on the held newline in fill mode.
+func (w *Writer) joinLines() {
+	if w.newlines == 1 {
+		w.writeWord() // the word is ended by the newline
+		if w.space.Len() == 0 {
+			w.writeRune(w.lexRune(' '))
+		}
+	}
+	w.newlines = 0
+}
+
+// writeRune processes the rune of the source text by its class.
+func (w *Writer) writeRune(t lexeme) {
+	c := t.c
+	switch t.kind {
+	case lexZero: // ANSI escape sequence or zero width no-break space
+		w.word.WriteRune(c)
+		w.wordEnd = w.srcEnd
+	case lexInvisible: // dropped break opportunity
+		if w.wordLen > 0 && (len(w.breaks) == 0 ||
+			w.breaks[len(w.breaks)-1].size < w.word.Len()) {
+			w.breaks = append(w.breaks,
+				wordBreak{size: w.word.Len(), length: w.wordLen, fit: true})
+		}
+	case lexNewline: // end of current line
+		w.writeWord()
+		if w.nlSpace && w.space.Len() > 0 {
+			// keep the trailing spaces of the source line
+			w.writePrefix()
+			w.writeSpaces()
+		}
+		w.writeNewLine()
+		if w.quoteMarker != "" {
+			// detect the quote markers of the next line
+			w.quoting = true
+			w.quote, w.quoteLen = "", 0
+		}
+	case lexSpace: // end of current word
+		w.writeWord()
+		if c == '\t' && w.tabWidh > 0 {
+			// Replace tabs with spaces while preserving alignment. The
+			// column includes the just written word, the pending prefix
+			// and the preceding spaces.
+			col := w.column() + w.space.Len()
+			w.space.Write(bytes.Repeat([]byte{' '}, w.tabWidh-col%w.tabWidh))
+		} else if c == '\t' && w.tabSpace {
+			w.space.WriteByte(' ')
+		} else {
+			w.space.WriteRune(c)
+		}
+	default: // any other character, including breakpoints
+		if w.wordLen > 0 && w.breaksWords() {
+			w.markBreak(c)
+		}
+		w.word.WriteRune(c)
+		w.wordEnd = w.srcEnd
+		w.wordLen += w.runeWidth(c)
+		w.wrapWord()
+	}
+}
+
+// wrapWord adds a line break if the current word would exceed the line's
+// character limit.
+func (w *Writer) wrapWord() {
+	if w.hyphenate != nil || w.width < 1 ||
+		w.column()+w.wordLen+w.space.Len() <= w.lineWidth() {
+		return
+	}
+	if i := w.wordBreak(); i >= 0 {
+		// break the word on the best word break position
+		w.writeWordPart(w.breaks[i].size, w.breaks[i].length)
+		w.writeBreak()
+	} else if w.split.size > 0 {
+		// break the line on the last breakpoint instead of the space
+		w.splitLine()
+		if w.column()+w.wordLen+w.space.Len() > w.lineWidth() &&
+			w.wordLen <= w.lineWidth() && !w.lineEmpty() {
+			w.writeBreak()
+		}
+	} else if w.wordLen <= w.lineWidth() && !w.lineEmpty() {
+		// move the word to the next line if the current line is
+		// not empty: every line gets at least one word, even if
+		// the prefix does not leave room for it
+		w.writeBreak()
+	}
+}
+
+// writeObject writes the embedded object if b starts with its marker and
+// returns the size of the marker.
+func (w *Writer) writeObject(b []byte) int {
+	for _, o := range w.objects {
+		if !bytes.HasPrefix(b, []byte(o.marker)) {
+			continue
+		}
+		if w.fill {
+			w.joinLines()
+		}
+		w.word.WriteString(o.marker)
+		w.wordEnd = w.srcOff + len(o.marker)
+		w.wordLen += o.width
+		w.wrapWord()
+		return len(o.marker)
+	}
+	return 0
+}
+
+// Flush writes any buffered data to the underlying io.Writer. Trailing
+// whitespace is still stripped and no newline is added, unless the final
+// newline is enabled with SetFinalNewline.
+func (w *Writer) Flush() error {
+	if w.dst.err != nil {
+		return w.dst.err
+	}
+	if w.quoting && w.quoteRun.Len() > 0 {
+		w.endQuote()
+	}
+	if w.row.Len() > 0 && !w.writeRow() {
+		w.replayRow()
+	}
+	if w.newlines == 1 {
+		// the held newline is not followed by the text
+		w.writeWord()
+		w.writeRune(w.lexRune('\n'))
+	}
+	w.newlines = 0
+	if err := w.writeWord(); err != nil {
+		return err
+	}
+	if err := w.writeFinalSpace(); err != nil {
+		return err
+	}
+	var err error
+	if w.finalNewLine && w.started && !w.newLine && w.hasText() {
+		err = w.writeNewLine()
+	} else {
+		err = w.writeLine()
+	}
+	if err != nil {
+		return err
+	}
+	return w.dst.flush()
+}
+
+// SetCloseDestination defines whether Close closes the underlying io.Writer,
+// if it implements io.Closer. By default the destination is left open.
+func (w *Writer) SetCloseDestination(b bool) {
+	w.closeDst = b
+}
+
+// Close flushes buffered data and closes the underlying io.Writer if it is
+// enabled with SetCloseDestination and the io.Writer implements io.Closer.
+// Close does not emit a trailing newline, unless it is enabled with
+// SetFinalNewline. Calling Close more than once is safe: subsequent calls do
+// nothing and return nil.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.Flush()
+	if !w.closeDst {
+		return err
+	}
+	if c, ok := w.dst.writer.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// WriteTo implements io.WriterTo. It flushes the pending data and drains the
+// internal buffer of a Writer created with NewBuffer to dst. For other Writers
+// it returns ErrNoBuffer.
+func (w *Writer) WriteTo(dst io.Writer) (n int64, err error) {
+	if w.buf == nil {
+		return 0, ErrNoBuffer
+	}
+	if err = w.Flush(); err != nil {
+		return 0, err
+	}
+	return w.buf.WriteTo(dst)
+}
+
+// Reader returns the io.Reader that drains the internal buffer of a Writer
+// created with NewBuffer, flushing the pending data first, so the wrapped text
+// can be copied with io.Copy. It implements io.WriterTo with WriteTo of the
+// Writer. For other Writers the reader returns ErrNoBuffer.
+func (w *Writer) Reader() io.Reader {
+	return bufferReader{w}
+}
+
+// bufferReader reads the internal buffer of the Writer.
+type bufferReader struct {
+	w *Writer
+}
+
+func (r bufferReader) Read(p []byte) (n int, err error) {
+	if r.w.buf == nil {
+		return 0, ErrNoBuffer
+	}
+	if err = r.w.Flush(); err != nil {
+		return 0, err
+	}
+	return r.w.buf.Read(p)
+}
+
+func (r bufferReader) WriteTo(dst io.Writer) (n int64, err error) {
+	return r.w.WriteTo(dst)
 }
 
 // WriteString implement io.WrieString. It returns the number of bytes written
-// and any write error encountered.
+// and any write error encountered. The string is wrapped as Write does, but
+// without copying it to a byte slice.
 func (w *Writer) WriteString(str string) (n int, err error) {
-	return w.Write([]byte(str))
+	if w.dst.err != nil || w.dst.truncated || w.verbatim || w.noWrap() ||
+		len(w.objects) > 0 {
+		return w.Write([]byte(str))
+	}
+	n, err = w.skipHead(len(str), func(i int) (rune, int) {
+		return utf8.DecodeRuneInString(str[i:])
+	})
+	if err != nil {
+		return 0, err
+	}
+	str = str[n:]
+	for len(str) > 0 && w.dst.err == nil {
+		c, size := utf8.DecodeRuneInString(str) // current rune
+		w.srcOff, w.srcEnd = w.consumed+n, w.consumed+n+size
+		w.sourceRune(w.scan(c, size, str[0]))
+		str = str[size:] // skip rune from source
+		n += size
+	}
+	// output last word
+	w.writeWord()
+	w.consumed += n
+	return n, w.dst.err
+}
+
+// WriteAtomic writes s as a single unbreakable word. It is placed on the
+// current line if it fits there, otherwise it is moved to the next line in
+// full. The string is never broken inside, even if it is wider than the line:
+// it overflows the width, as any long word does. The spaces inside s are kept
+// as is and s should not contain newlines. In the verbatim mode and without
+// wrapping s is written as Write does.
+func (w *Writer) WriteAtomic(s string) (n int, err error) {
+	if w.dst.err != nil || w.dst.truncated || w.verbatim || w.noWrap() ||
+		len(s) == 0 {
+		return w.Write([]byte(s))
+	}
+	if !w.started {
+		if err = w.begin(false); err != nil {
+			return 0, err
+		}
+	}
+	w.trimmed = true
+	if w.quoting {
+		w.endQuote()
+	}
+	if w.row.Len() > 0 {
+		w.replayRow()
+	}
+	if w.fill {
+		w.joinLines()
+	}
+	w.writeWord() // the word is ended by the atomic text
+	w.srcOff, w.srcEnd = w.consumed, w.consumed+len(s)
+	w.word.WriteString(s)
+	w.wordEnd = w.srcEnd
+	w.wordLen = w.measure(s)
+	if w.width > 0 && w.column()+w.wordLen+w.space.Len() > w.lineWidth() &&
+		!w.lineEmpty() {
+		w.writeBreak()
+	}
+	w.putWord() // the atomic text is never hyphenated
+	w.consumed += len(s)
+	w.inLine = true
+	return len(s), w.dst.err
 }
 
 // WriteByte write byte to Writer.
@@ -235,3 +2077,80 @@ func (w *Writer) WriteRune(r rune) (n int, err error) {
 func (w *Writer) Printf(format string, a ...interface{}) (n int, err error) {
 	return fmt.Fprintf(w, format, a...)
 }
+
+// Measure returns the number of lines the string occupies when it is wrapped
+// from the start of the text with the current configuration, including the
+// prefixes, the tab width and the breakpoints. The text is wrapped by a
+// temporary clone of the Writer, so neither the output nor the state of the
+// Writer is changed. The line transformation is not called.
+func (w *Writer) Measure(s string) int {
+	var counter lineCounter
+	var c = w.clone(&counter)
+	c.skipTransform()
+	c.WriteString(s)
+	c.Flush()
+	return counter.count()
+}
+
+// WrapPositions returns the byte offsets in s where the line breaks would be
+// inserted by wrapping it from the start of the text with the current
+// configuration. Each offset points just after the last byte that stays on the
+// line, so the spaces dropped at the break follow it. Like Measure, it uses a
+// temporary clone of the Writer and produces no output.
+func (w *Writer) WrapPositions(s string) []int {
+	var c = w.clone(ioutil.Discard)
+	c.skipTransform()
+	c.withWraps = true
+	c.WriteString(s)
+	c.Flush()
+	return c.positions
+}
+
+// skipTransform replaces the line transformation of a clone with the identity,
+// so the clone writes the lines the same way without calling it.
+func (w *Writer) skipTransform() {
+	if w.transform != nil {
+		w.transform = func(s string) string { return s }
+	}
+}
+
+// clone returns a new Writer over dst with the same configuration. The whole
+// Writer is copied, and then the streaming state, the buffers and the output
+// are reset, so the clone does not share them with the Writer.
+func (w *Writer) clone(dst io.Writer) *Writer {
+	var c = new(Writer)
+	*c = *w
+	c.dst = destination{writer: dst}
+	c.writer = &c.dst
+	c.buf = nil
+	c.widthPending = false
+	c.space, c.word, c.line = bytes.Buffer{}, bytes.Buffer{}, bytes.Buffer{}
+	c.quoteRun, c.row = bytes.Buffer{}, bytes.Buffer{}
+	c.breaks, c.breakList, c.positions = nil, nil, nil
+	c.withStats, c.withBreaks, c.withWraps = false, false, false
+	c.closeDst = false
+	c.Reset()
+	return c
+}
+
+// lineCounter counts the lines of the written text.
+type lineCounter struct {
+	lines   int  // number of newlines
+	pending bool // the last line is not terminated
+}
+
+func (c *lineCounter) Write(b []byte) (int, error) {
+	if len(b) > 0 {
+		c.lines += bytes.Count(b, []byte{'\n'})
+		c.pending = b[len(b)-1] != '\n'
+	}
+	return len(b), nil
+}
+
+// count returns the number of lines, including the unterminated last line.
+func (c *lineCounter) count() int {
+	if c.pending {
+		return c.lines + 1
+	}
+	return c.lines
+}
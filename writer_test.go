@@ -0,0 +1,1619 @@
+package wordwrap_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/mdigger/wordwrap"
+)
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		width  uint
+		prefix string
+		err    error
+	}{
+		{0, "", nil},
+		{0, "> ", nil},
+		{10, "", nil},
+		{10, "> ", nil},
+		{3, "> ", nil},
+		{2, "> ", wordwrap.ErrNoContentWidth},
+		{1, ">>>", wordwrap.ErrNoContentWidth},
+		{4, "»»»»", wordwrap.ErrNoContentWidth},
+	} {
+		w := wordwrap.New(new(bytes.Buffer), test.width)
+		w.SetPrefix(test.prefix)
+		if err := w.Validate(); err != test.err {
+			t.Errorf("width %d, prefix %q: got %v, want %v",
+				test.width, test.prefix, err, test.err)
+		}
+	}
+
+	w := wordwrap.New(new(bytes.Buffer), 3)
+	w.SetFirstLinePrefix(">>>>>")
+	if err := w.Validate(); err != wordwrap.ErrNoContentWidth {
+		t.Errorf("first line prefix: got %v, want %v", err, wordwrap.ErrNoContentWidth)
+	}
+	for _, line := range []int{0, 1} {
+		w = wordwrap.New(new(bytes.Buffer), 3)
+		w.SetPrefixFunc(func(n int) string {
+			if n == line {
+				return ">>>>>"
+			}
+			return ""
+		})
+		if err := w.Validate(); err != wordwrap.ErrNoContentWidth {
+			t.Errorf("prefix of line %d: got %v, want %v",
+				line, err, wordwrap.ErrNoContentWidth)
+		}
+	}
+}
+
+func TestPrefixCountsTowardWidth(t *testing.T) {
+	const source = "aaaa bbbb cccc dddd eeee ffff gggg hhhh iiii"
+	for _, test := range []struct {
+		counts bool
+		want   string
+	}{
+		{true, "aaaa bbbb cccc dddd\n" +
+			">>>> eeee ffff gggg\n" +
+			">>>> hhhh iiii"},
+		{false, "aaaa bbbb cccc dddd\n" +
+			">>>> eeee ffff gggg hhhh\n" +
+			">>>> iiii"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 20)
+		w.SetPrefix(">>>> ")
+		w.SetPrefixCountsTowardWidth(test.counts)
+		w.WriteString(source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("counts %v:\ngot:\n%s\nwant:\n%s", test.counts, got, test.want)
+		}
+	}
+}
+
+type closeBuffer struct {
+	bytes.Buffer
+	closed int
+}
+
+func (b *closeBuffer) Close() error {
+	b.closed++
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	for _, closeDst := range []bool{false, true} {
+		var buf closeBuffer
+		w := wordwrap.New(&buf, 10)
+		var _ io.WriteCloser = w
+		w.SetCloseDestination(closeDst)
+		w.WriteString("lorem ipsum dolor ")
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal("second close:", err)
+		}
+		want := 0
+		if closeDst {
+			want = 1
+		}
+		if buf.closed != want {
+			t.Errorf("close destination %v: destination closed %d times",
+				closeDst, buf.closed)
+		}
+		if got, want := buf.String(), "lorem\nipsum\ndolor"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestNewBuffer(t *testing.T) {
+	w := wordwrap.NewBuffer(10)
+	for _, test := range []struct {
+		source, want string
+	}{
+		{"lorem ipsum dolor", "lorem\nipsum\ndolor"},
+		{"sit amet", "sit amet"},
+	} {
+		w.WriteString("discarded text")
+		w.Reset()
+		w.WriteString(test.source)
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, w.Reader()); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+
+	w = wordwrap.New(new(bytes.Buffer), 10)
+	if _, err := w.WriteTo(ioutil.Discard); err != wordwrap.ErrNoBuffer {
+		t.Errorf("WriteTo: got %v, want %v", err, wordwrap.ErrNoBuffer)
+	}
+	if _, err := ioutil.ReadAll(w.Reader()); err != wordwrap.ErrNoBuffer {
+		t.Errorf("Reader: got %v, want %v", err, wordwrap.ErrNoBuffer)
+	}
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestFrom(t *testing.T) {
+	const source = "Съешь же ещё этих мягких французских булок"
+	const want = "Съешь же ещё\nэтих мягких\nфранцузских\nбулок"
+	got, err := wordwrap.From(iotest.OneByteReader(strings.NewReader(source)), 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	readErr := errors.New("read error")
+	got, err = wordwrap.From(io.MultiReader(
+		iotest.HalfReader(strings.NewReader(source[:30])),
+		errReader{readErr}), 15)
+	if err != readErr {
+		t.Errorf("got error %v, want %v", err, readErr)
+	}
+	if want := wordwrap.String(source[:30], 15); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestExactWidth(t *testing.T) {
+	for _, test := range []struct {
+		width        uint
+		prefix       string
+		source, want string
+	}{
+		{11, "", "lorem ipsum", "lorem ipsum"},
+		{11, "", "lorem ipsum dolor", "lorem ipsum\ndolor"},
+		{11, "", "lorem ipsums", "lorem\nipsums"},
+		{11, "", "abcdefghijk", "abcdefghijk"},
+		{13, "> ", "lorem ipsum dolor sitam", "lorem ipsum\n> dolor sitam"},
+		{13, "> ", "lorem ipsum dolor sitamet", "lorem ipsum\n> dolor\n> sitamet"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, test.width)
+		w.SetPrefix(test.prefix)
+		w.WriteString(test.source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("width %d, %q: got %q, want %q", test.width, test.source, got, test.want)
+		}
+	}
+}
+
+func TestHangingBullet(t *testing.T) {
+	for _, test := range []struct {
+		marker, want string
+	}{
+		{"10. ", "10. Lorem ipsum dolor sit\n" +
+			"    amet, lectus sed ut at\n" +
+			"    lacinia."},
+		{"• ", "• Lorem ipsum dolor sit amet,\n" +
+			"  lectus sed ut at lacinia."},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 30)
+		w.SetHangingBullet(test.marker)
+		w.WriteString("Lorem ipsum dolor sit amet, lectus sed ut at lacinia.")
+		if got := buf.String(); got != test.want {
+			t.Errorf("marker %q:\ngot:\n%s\nwant:\n%s", test.marker, got, test.want)
+		}
+	}
+}
+
+func TestBreakOnCase(t *testing.T) {
+	for _, test := range []struct {
+		source, want string
+	}{
+		{"call getUserAccountSettingsFromRemoteServer now",
+			"call\ngetUserAccount\nSettingsFromRemote\nServer now"},
+		{"read user_account_settings_from_remote_server",
+			"read\nuser_account_\nsettings_from_\nremote_server"},
+		{"call getUserAccount now", "call getUserAccount\nnow"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 20)
+		w.SetBreakOnCase(true)
+		w.WriteString(test.source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestPunctuationBreaks(t *testing.T) {
+	const source = "see very-long-hyphenated,comma-joined;text here"
+	for _, test := range []struct {
+		punct, want string
+	}{
+		{"", "see\nvery-long-hyphenated,comma-joined;text\nhere"},
+		{".,;:", "see\nvery-long-hyphenated,\ncomma-joined;text\nhere"},
+		{"-,;", "see very-long-\nhyphenated,comma-\njoined;text here"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 20)
+		w.SetPunctuationBreaks(test.punct)
+		w.WriteString(source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("punctuation %q: got %q, want %q", test.punct, got, test.want)
+		}
+	}
+}
+
+func TestLongWordNoEmptyLine(t *testing.T) {
+	for source, want := range map[string]string{
+		"aaaa bbbbbbbbbb cc":           "aaaa\nbbbbbbbbbb\ncc",
+		"aaaa bbbbbbbbbbbbbbbbbbbb cc": "aaaa\nbbbbbbbbbbbbbbbbbbbb\ncc",
+		"bbbbbbbbbbbbbbbbbbbb cc":      "bbbbbbbbbbbbbbbbbbbb\ncc",
+	} {
+		if got := wordwrap.String(source, 10); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestPrefixWiderThanWidth(t *testing.T) {
+	for source, want := range map[string]string{
+		"abc":          "abc",
+		"a b c":        "a\n>>>b\n>>>c",
+		"abc def\nghi": "abc\n>>>def\n>>>ghi",
+		"a\n\nb":       "a\n>>>\n>>>b",
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 1)
+		w.SetPrefix(">>>")
+		w.WriteString(source)
+		if got := buf.String(); got != want {
+			t.Errorf("%q: got %q, want %q", source, got, want)
+		}
+	}
+}
+
+func TestNoWrapPrefix(t *testing.T) {
+	for source, want := range map[string]string{
+		"a\nb\nc":       "a\n> b\n> c",
+		"a\n\nb\n":      "a\n> \n> b\n",
+		"a  \n  b\t c ": "a  \n>   b\t c ",
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 0)
+		w.SetPrefix("> ")
+		for _, line := range strings.SplitAfter(source, "\n") {
+			w.WriteString(line)
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("%q: got %q, want %q", source, got, want)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 20)
+	w.SetPrefix("> ")
+	w.SetTransform(func(line string) string {
+		return "\x1b[1m" + strings.ToUpper(line) + "\x1b[0m"
+	})
+	w.WriteString("Lorem ipsum dolor sit amet, lectus sed ut at lacinia.\n\nEnd")
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	const want = "\x1b[1mLOREM IPSUM DOLOR\x1b[0m\n" +
+		"> \x1b[1mSIT AMET, LECTUS\x1b[0m\n" +
+		"> \x1b[1mSED UT AT LACINIA.\x1b[0m\n" +
+		"> \x1b[1m\x1b[0m\n" +
+		"> \x1b[1mEND\x1b[0m"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestDirectionRTL(t *testing.T) {
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 20)
+	w.SetDirection(wordwrap.RTL)
+	w.SetPrefix("|")
+	w.WriteString("שלום עולם, זהו טקסט ארוך.\n  lorem  \n\nipsum\nמה?!\n...")
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	const want = " שלום עולם, זהו טקסט\n" +
+		"|              .ארוך\n" +
+		"|              lorem\n" +
+		"|\n" +
+		"|              ipsum\n" +
+		"|               !?מה\n" +
+		"|                ..."
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHyphenator(t *testing.T) {
+	points := map[string][]int{
+		"hyphenation":     {2, 6},
+		"algorithm":       {2, 4},
+		"extraordinarily": {5, 7, 9, 11, 13},
+	}
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 14)
+	w.SetPrefix("> ")
+	w.SetHyphenator(func(word string) []int { return points[word] })
+	w.WriteString("The hyphenation algorithm is extraordinarily good at " +
+		"extraordinarily long words: pneumonoultramicroscopic")
+	const want = "The hyphen-\n" +
+		"> ation algo-\n" +
+		"> rithm is\n" +
+		"> extraordina-\n" +
+		"> rily good at\n" +
+		"> extraordina-\n" +
+		"> rily long\n" +
+		"> words:\n" +
+		"> pneumonoultramicroscopic"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// the breakpoints are used if no hyphenation point fits
+	const source = "see aaaa-bbbb-cccc-dddd now"
+	for _, test := range []struct {
+		points []int
+		want   string
+	}{
+		{nil, "see aaaa-\nbbbb-cccc-\ndddd now"},
+		{[]int{2, 11}, "see aa-\naa-bbbb-c-\nccc-dddd now"},
+	} {
+		hyphenator := func(w *wordwrap.Writer) {
+			w.SetHyphenator(func(string) []int { return test.points })
+		}
+		if got := wordwrap.String(source, 12, wordwrap.WithBreakpoints("-"),
+			hyphenator); got != test.want {
+			t.Errorf("points %v: got %q, want %q", test.points, got, test.want)
+		}
+	}
+}
+
+func TestBOM(t *testing.T) {
+	for _, test := range []struct {
+		strip        bool
+		source, want string
+	}{
+		{false, "\uFEFFHello world", "\uFEFFHello world"},
+		{true, "\uFEFFHello world", "Hello world"},
+		{false, "\uFEFFHello wide world", "\uFEFFHello wide\nworld"},
+		{true, "Hello w\uFEFFide world", "Hello w\uFEFFide\nworld"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 12)
+		w.SetStripBOM(test.strip)
+		w.WriteString(test.source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestKeepExtraSpaces(t *testing.T) {
+	for _, test := range []struct {
+		keep         bool
+		source, want string
+	}{
+		{false, "Lorem ipsum. Dolor sit", "Lorem ipsum.\n> Dolor sit"},
+		{false, "Lorem ipsum.  Dolor sit", "Lorem ipsum.\n> Dolor sit"},
+		{true, "Lorem ipsum. Dolor sit", "Lorem ipsum.\n> Dolor sit"},
+		{true, "Lorem ipsum.  Dolor sit", "Lorem ipsum.\n>  Dolor sit"},
+		{true, "Lorem ipsum.   Dolor sit", "Lorem ipsum.\n>   Dolor sit"},
+		{true, "Lorem ipsum.  \nDolor sit", "Lorem ipsum.\n> Dolor sit"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 15)
+		w.SetPrefix("> ")
+		w.SetKeepExtraSpaces(test.keep)
+		w.WriteString(test.source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("keep %v, %q: got %q, want %q", test.keep, test.source, got, test.want)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 20)
+	w.SetPrefix("> ")
+	if got := w.Stats(); got != (wordwrap.Stats{}) {
+		t.Errorf("stats are collected: %+v", got)
+	}
+	w.SetCollectStats(true)
+	w.WriteString("Lorem ipsum dolor sit amet, lectus sed ut at lacinia.\n\nEnd")
+	// "Lorem ipsum dolor", "> sit amet, lectus", "> sed ut at lacinia.",
+	// "> ", "> End"
+	want := wordwrap.Stats{Lines: 5, MaxLen: 20, Slack: 3 + 2 + 0 + 18 + 15}
+	got := w.Stats()
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if avg := got.AverageSlack(); avg != 38.0/5 {
+		t.Errorf("average slack %v", avg)
+	}
+	w.Reset()
+	if got := w.Stats(); got != (wordwrap.Stats{}) {
+		t.Errorf("stats are not reset: %+v", got)
+	}
+
+	w = wordwrap.New(&buf, 0)
+	w.SetCollectStats(true)
+	w.WriteString("aaa\nbbbbb\nccc")
+	if got, want := w.Stats(), (wordwrap.Stats{Lines: 3, MaxLen: 5}); got != want {
+		t.Errorf("width 0: got %+v, want %+v", got, want)
+	}
+}
+
+func TestBreakpointPriority(t *testing.T) {
+	const source = "see https://example.com/some-long-path/to-the-resource.html now"
+	for _, test := range []struct {
+		priority, want string
+	}{
+		{"", "see https://example.com/\nsome-long-path/to-the-\nresource.html now"},
+		{"-", "see https://example.com/\nsome-long-path/to-the-\nresource.html now"},
+		{"/", "see https://example.com/\nsome-long-path/\nto-the-resource.html now"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 24)
+		w.SetBreakpoints("-/")
+		w.SetBreakpointPriority(test.priority)
+		w.WriteString(source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("priority %q:\ngot:\n%s\nwant:\n%s", test.priority, got, test.want)
+		}
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	for source, want := range map[string]string{
+		"lorem ipsum-dolor-sit": "lorem ipsum-\ndolor-sit",
+		"lorem -ipsum dolor":    "lorem -ipsum\ndolor",
+		"lorem-ipsum-dolor-sit": "lorem-ipsum-\ndolor-sit",
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 14)
+		w.SetBreakpoints("-")
+		w.WriteString(source)
+		if got := buf.String(); got != want {
+			t.Errorf("%q: got %q, want %q", source, got, want)
+		}
+	}
+}
+
+func TestFinalNewline(t *testing.T) {
+	for _, test := range []struct {
+		width        uint
+		source, want string
+	}{
+		{10, "", ""},
+		{10, "lorem", "lorem\n"},
+		{10, "lorem ipsum dolor", "lorem\nipsum\ndolor\n"},
+		{10, "lorem\n", "lorem\n"},
+		{10, "lorem\n\n", "lorem\n\n"},
+		{0, "", ""},
+		{0, "lorem ipsum dolor", "lorem ipsum dolor\n"},
+		{0, "lorem\n", "lorem\n"},
+		{10, "   ", ""},
+		{10, "\uFEFF", "\uFEFF"},
+		{10, "\uFEFF  ", "\uFEFF"},
+		{10, "lorem  ", "lorem\n"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, test.width)
+		w.SetFinalNewline(true)
+		w.WriteString(test.source)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("width %d, %q: got %q, want %q",
+				test.width, test.source, got, test.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 10)
+	w.SetFinalNewline(true)
+	w.SetTrimLeadingSpace(true)
+	w.WriteString("  \n ")
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "" {
+		t.Errorf("trimmed whitespace: got %q, want empty", got)
+	}
+}
+
+func TestTabs(t *testing.T) {
+	for _, test := range []struct {
+		tabWidth     int
+		source, want string
+	}{
+		{2, "ab\tcd", "ab  cd"},
+		{4, "ab\tcd", "ab  cd"},
+		{8, "ab\tcd", "ab      cd"},
+		{4, "abcd\tef", "abcd    ef"},
+		{4, "ab \tcd", "ab  cd"},
+		{4, "abc \tcd", "abc     cd"},
+		{4, "a\tb\tc", "a   b   c"},
+		{4, "ab\t\tcd", "ab      cd"},
+		{4, "lorem\nab\tcd", "lorem\n> ab    cd"},
+		{3, "lorem\n\tcd", "lorem\n>  cd"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 20)
+		w.SetPrefix("> ")
+		w.SetTabWidth(test.tabWidth)
+		w.WriteString(test.source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("tab width %d, %q: got %q, want %q",
+				test.tabWidth, test.source, got, test.want)
+		}
+	}
+}
+
+func TestTabWidthZero(t *testing.T) {
+	for _, test := range []struct {
+		asSpace, verbatim bool
+		source, want      string
+	}{
+		{false, false, "ab\tcd\tef\tgh\tij", "ab\tcd\tef\ngh\tij"},
+		{false, false, "lorem ipsum\t\t", "lorem\nipsum"},
+		{false, true, "ab\tcd\tef\tgh\tij", "ab\tcd\tef\tg\nh\tij"},
+		{false, true, "lorem ipsum\t\t", "lorem ipsu\nm\t\t"},
+		{true, false, "ab\tcd\tef\tgh\tij", "ab cd ef\ngh ij"},
+		{true, false, "lorem ipsum\t\t", "lorem\nipsum"},
+		{true, true, "ab\tcd\tef\tgh\tij", "ab cd ef g\nh ij"},
+		{true, true, "lorem ipsum\t\t", "lorem ipsu\nm  "},
+	} {
+		for _, unit := range []wordwrap.Unit{wordwrap.UnitRunes, wordwrap.UnitDisplay} {
+			var buf bytes.Buffer
+			w := wordwrap.New(&buf, 10)
+			w.SetWidthUnit(unit)
+			w.SetTabAsSpace(test.asSpace)
+			w.SetVerbatim(test.verbatim)
+			w.WriteString(test.source)
+			if got := buf.String(); got != test.want {
+				t.Errorf("as space %v, verbatim %v, %q: got %q, want %q",
+					test.asSpace, test.verbatim, test.source, got, test.want)
+			}
+		}
+	}
+
+	for _, asSpace := range []bool{false, true} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 0)
+		w.SetTabAsSpace(asSpace)
+		w.WriteString("a\tb  \nc ")
+		want := "a\tb  \nc "
+		if asSpace {
+			want = "a b  \nc "
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("width 0, as space %v: got %q, want %q", asSpace, got, want)
+		}
+	}
+}
+
+func TestWriteAtomic(t *testing.T) {
+	for _, test := range []struct {
+		before, atomic, after, want string
+	}{
+		{"lorem ", "12:00", " ipsum", "lorem 12:00\nipsum"},
+		{"lorem ipsum ", "12:00:00", " dolor", "lorem ipsum\n12:00:00\ndolor"},
+		{"lorem ", "[status 200 OK]", " ipsum", "lorem\n[status 200 OK]\nipsum"},
+		{"lorem ", "0123456789abcdef", " ipsum", "lorem\n0123456789abcdef\nipsum"},
+		{"", "0123456789 abcdef", "", "0123456789 abcdef"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 12)
+		w.SetBreakpoints(":")
+		w.WriteString(test.before)
+		n, err := w.WriteAtomic(test.atomic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(test.atomic) {
+			t.Errorf("%q: wrote %d bytes, want %d", test.atomic, n, len(test.atomic))
+		}
+		w.WriteString(test.after)
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.atomic, got, test.want)
+		}
+	}
+}
+
+func TestPrefixFunc(t *testing.T) {
+	const source = "lorem ipsum dolor sit amet\nlectus sed\nut at"
+	for _, test := range []struct {
+		width    uint
+		verbatim bool
+		want     string
+	}{
+		{0, false, "lorem ipsum dolor sit amet\n>lectus sed\n>>ut at"},
+		{12, false, "lorem ipsum\n>dolor sit\n>>amet\nlectus sed\n>ut at"},
+		{12, true, "lorem ipsum \n>dolor sit a\n>>met\nlectus sed\n>ut at"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, test.width)
+		w.SetVerbatim(test.verbatim)
+		w.SetPrefixFunc(func(lineNum int) string {
+			return strings.Repeat(">", lineNum%3)
+		})
+		w.WriteString(source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("width %d, verbatim %v: got %q, want %q",
+				test.width, test.verbatim, got, test.want)
+		}
+		// the lines are numbered from the start after reset
+		buf.Reset()
+		w.Reset()
+		w.WriteString(source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("width %d, verbatim %v, reset: got %q, want %q",
+				test.width, test.verbatim, got, test.want)
+		}
+	}
+}
+
+func TestSoftBreak(t *testing.T) {
+	const source = "lorem ipsum dolor sit\namet, lectus sed"
+	for _, test := range []struct {
+		soft, want string
+	}{
+		{"\n", "lorem ipsum\ndolor sit\namet, lectus\nsed"},
+		{"\\\n", "lorem ipsum\\\ndolor sit\namet, lectus\\\nsed"},
+		{" ", "lorem ipsum dolor sit\namet, lectus sed"},
+		{"", "lorem ipsumdolor sit\namet, lectussed"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 12)
+		w.SetCollectBreaks(true)
+		w.SetSoftBreak(test.soft)
+		w.WriteString(source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.soft, got, test.want)
+		}
+		if got := len(w.Breaks()); got != 3 {
+			t.Errorf("%q: got %d breaks, want 3", test.soft, got)
+		}
+	}
+}
+
+func TestTrimLeadingSpace(t *testing.T) {
+	for _, test := range []struct {
+		chunks []string
+		want   string
+	}{
+		{[]string{"  lorem ipsum"}, "lorem ipsum"},
+		{[]string{"\t\tlorem\n\n  ipsum"}, "lorem\n\n  ipsum"},
+		{[]string{"\n\n \n", "\t", "  lorem", " ipsum"}, "lorem ipsum"},
+		{[]string{" ", "", "\n", "lorem\n", "\n", " ipsum"}, "lorem\n\n ipsum"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 20)
+		w.SetKeepExtraSpaces(true)
+		w.SetTrimLeadingSpace(true)
+		for i, chunk := range test.chunks {
+			var n int
+			if i%2 == 0 {
+				n, _ = w.WriteString(chunk)
+			} else {
+				n, _ = w.Write([]byte(chunk))
+			}
+			if n != len(chunk) {
+				t.Errorf("%q: wrote %d bytes, want %d", chunk, n, len(chunk))
+			}
+		}
+		w.Flush()
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.chunks, got, test.want)
+		}
+	}
+}
+
+func TestContinuationMarker(t *testing.T) {
+	const source = "lorem ipsum dolor sit\namet, lectus sed ut at"
+	for _, test := range []struct {
+		verbatim bool
+		want     string
+	}{
+		{false, "lorem ipsum\\\ndolor sit\namet,\\\nlectus sed\\\nut at"},
+		{true, "lorem ipsum\\\n dolor sit\namet, lectu\\\ns sed ut at"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 12)
+		w.SetVerbatim(test.verbatim)
+		w.SetContinuationMarker("\\")
+		w.WriteString(source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("verbatim %v: got %q, want %q", test.verbatim, got, test.want)
+		}
+	}
+}
+
+func TestVerbatim(t *testing.T) {
+	for _, test := range []struct {
+		tabWidth     int
+		source, want string
+	}{
+		{0, "0123456789abcdef", "0123456789\n> abcdef"},
+		{0, "  lorem   ipsum  \n\n  dolor", "  lorem   \n> ipsum  \n> \n>   dolor"},
+		{4, "if x {\n\treturn  y\n}", "if x {\n>   return\n>   y\n> }"},
+		{4, "a\tb\tc\td", "a   b   c \n> d"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 10)
+		w.SetPrefix("> ")
+		w.SetTabWidth(test.tabWidth)
+		w.SetVerbatim(true)
+		w.WriteString(test.source)
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+// shortWriter accepts at most limit bytes per write.
+type shortWriter struct {
+	bytes.Buffer
+	limit int
+}
+
+func (w *shortWriter) Write(b []byte) (int, error) {
+	if len(b) > w.limit {
+		b = b[:w.limit]
+	}
+	return w.Buffer.Write(b)
+}
+
+func TestShortWrite(t *testing.T) {
+	const source = "Lorem ipsum dolor sit amet, lectus sed ut at lacinia."
+	dst := &shortWriter{limit: 1}
+	w := wordwrap.New(dst, 20)
+	w.SetPrefix("> ")
+	n, err := w.WriteString(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(source) {
+		t.Errorf("got %d bytes, want %d", n, len(source))
+	}
+	const want = "Lorem ipsum dolor\n> sit amet, lectus\n> sed ut at lacinia."
+	if got := dst.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	w = wordwrap.New(&shortWriter{limit: 0}, 20)
+	if _, err := w.WriteString(source); err != io.ErrShortWrite {
+		t.Errorf("got error %v, want %v", err, io.ErrShortWrite)
+	}
+	if _, err := w.WriteString(source); err != io.ErrShortWrite {
+		t.Errorf("error is not sticky: %v", err)
+	}
+	if err := w.Flush(); err != io.ErrShortWrite {
+		t.Errorf("flush error is not sticky: %v", err)
+	}
+}
+
+func TestQuotePrefix(t *testing.T) {
+	const source = "> Lorem ipsum dolor sit amet, lectus sed.\n" +
+		"> > Nested quote that is long enough.\n" +
+		">>Tight nested quote text.\n" +
+		"Reply text that is long enough.\n" +
+		">\n" +
+		"> end"
+	const want = "> Lorem ipsum dolor sit\n> amet, lectus sed.\n" +
+		"> > Nested quote that is\n> > long enough.\n" +
+		">>Tight nested quote\n>>text.\n" +
+		"Reply text that is long\nenough.\n" +
+		">\n" +
+		"> end"
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 24)
+	w.SetQuotePrefix("> ")
+	if _, err := w.WriteString(source); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestComment(t *testing.T) {
+	const source = "Comment returns the word-wrapped comment block with every " +
+		"line prefixed with the marker, such as a double slash or a star. " +
+		"The marker counts toward the width.\n\n" +
+		"The blank lines hold the marker without the trailing spaces."
+	const want = "" +
+		"// Comment returns the word-wrapped comment block with every line prefixed with\n" +
+		"// the marker, such as a double slash or a star. The marker counts toward the\n" +
+		"// width.\n" +
+		"//\n" +
+		"// The blank lines hold the marker without the trailing spaces."
+	if got := wordwrap.Comment(source, 80, "// "); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := wordwrap.Comment("lorem ipsum dolor", 12, " * "),
+		" * lorem\n * ipsum\n * dolor"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	const source = "Lorem ipsum dolor sit amet, lectus sed ut at lacinia.\n\nwith\ttabs"
+	for _, width := range []uint{0, 10, 20, 40} {
+		want := strings.Count(wordwrap.String(source, width), "\n") + 1
+		if got := wordwrap.Height(source, width); got != want {
+			t.Errorf("width %d: got %d lines, want %d", width, got, want)
+		}
+	}
+	if got := wordwrap.Height("", 20); got != 0 {
+		t.Errorf("got %d lines for empty text, want 0", got)
+	}
+
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 20)
+	w.SetPrefix("> ")
+	w.SetTabWidth(4)
+	w.SetBreakpoints("-")
+	if _, err := w.WriteString("Lorem ipsum"); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Measure(source); got != 5 {
+		t.Errorf("got %d lines, want 5", got)
+	}
+	if _, err := w.WriteString(" dolor sit amet, lectus"); err != nil {
+		t.Fatal(err)
+	}
+	const want = "Lorem ipsum dolor\n> sit amet, lectus"
+	if got := buf.String(); got != want {
+		t.Errorf("state changed: got %q, want %q", got, want)
+	}
+
+	for _, test := range []struct {
+		source string
+		width  uint
+		config func(w *wordwrap.Writer)
+		want   int
+	}{
+		{"lorem ipsum\n\n  ", 0, func(w *wordwrap.Writer) { w.SetTransform(strings.ToUpper) }, 2},
+		{"lorem ipsum\n\n  ", 10, func(w *wordwrap.Writer) { w.SetTransform(strings.ToUpper) }, 3},
+		{"lorem ipsum dolor sit amet", 10, func(w *wordwrap.Writer) { w.SetSoftBreak(" ") }, 1},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, test.width)
+		test.config(w)
+		if got := w.Measure(test.source); got != test.want {
+			t.Errorf("%q width %d: got %d lines, want %d",
+				test.source, test.width, got, test.want)
+		}
+		w.WriteString(test.source)
+		w.Flush()
+		out := strings.TrimSuffix(buf.String(), "\n")
+		if got := strings.Count(out, "\n") + 1; got != test.want {
+			t.Errorf("%q width %d: output has %d lines, want %d",
+				test.source, test.width, got, test.want)
+		}
+	}
+}
+
+func TestKeepFinalSpace(t *testing.T) {
+	for _, test := range []struct {
+		source string
+		keep   bool
+		want   string
+	}{
+		{"Enter the name: ", false, "Enter the name:"},
+		{"Enter the name: ", true, "Enter the name: "},
+		{"Lorem ipsum dolor\nName: ", true, "Lorem ipsum dolor\nName: "},
+		{"Lorem ipsum dolor sit amet ", true, "Lorem ipsum dolor\nsit amet "},
+		{"Lorem ipsum dolor   ", true, "Lorem ipsum dolor   "},
+		{"Lorem ipsum dolor    ", true, "Lorem ipsum dolor"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 20)
+		w.SetKeepFinalSpace(test.keep)
+		if _, err := w.WriteString(test.source); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.source, got, test.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 10)
+	w.SetKeepFinalSpace(true)
+	w.WriteString("aaa bbb ")
+	w.WriteString("ccccc ")
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "aaa bbb\nccccc "; got != want {
+		t.Errorf("split writes: got %q, want %q", got, want)
+	}
+}
+
+func TestFill(t *testing.T) {
+	const source = "Lorem ipsum dolor\nsit amet, lectus sed\nut at lacinia.\n\n" +
+		"A adipiscing. \nVel placerat.\n\n\nEnd\n"
+	for _, test := range []struct {
+		width uint
+		want  string
+	}{
+		{20, "Lorem ipsum dolor\nsit amet, lectus sed\nut at lacinia.\n\n" +
+			"A adipiscing. Vel\nplacerat.\n\n\nEnd\n"},
+		{0, "Lorem ipsum dolor sit amet, lectus sed ut at lacinia.\n\n" +
+			"A adipiscing. Vel placerat.\n\n\nEnd\n"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, test.width)
+		w.SetFill(true)
+		// write by lines to check the newline held between writes
+		for _, line := range strings.SplitAfter(source, "\n") {
+			if _, err := w.WriteString(line); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("width %d: got %q, want %q", test.width, got, test.want)
+		}
+	}
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 20)
+	w.SetFill(true)
+	if _, err := w.WriteString(source); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	const want = "Lorem ipsum dolor\nsit amet, lectus sed\nut at lacinia.\n\n" +
+		"A adipiscing. Vel\nplacerat.\n\n\nEnd\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestInvisibleBreakpoints(t *testing.T) {
+	for _, test := range []struct {
+		source string
+		width  uint
+		want   string
+	}{
+		{"super|cali|fragilistic|expi|ali|docious", 15, "supercali\nfragilisticexpi\nalidocious"},
+		{"Lorem ip|sum do|lor", 20, "Lorem ipsum dolor"},
+		{"Lorem ipsum dolor#|sit", 20, "Lorem ipsum dolor#\nsit"},
+		{"|Lorem|| ipsum|", 0, "Lorem ipsum"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, test.width)
+		w.SetInvisibleBreakpoints("|")
+		if _, err := w.WriteString(test.source); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.source, got, test.want)
+		}
+	}
+
+	// whitespace can not be an invisible breakpoint
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 20)
+	w.SetInvisibleBreakpoints("| \n")
+	if err := w.Validate(); err != wordwrap.ErrIgnoredBreakpoints {
+		t.Errorf("got error %v, want %v", err, wordwrap.ErrIgnoredBreakpoints)
+	}
+	w.WriteString("lorem ip|sum\ndolor")
+	if got, want := buf.String(), "lorem ipsum\ndolor"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	w.SetInvisibleBreakpoints("|")
+	if err := w.Validate(); err != nil {
+		t.Errorf("error is not cleared: %v", err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	const source = "Lorem\tipsum dolor sit amet, lectus-sed-ut-at-lacinia."
+	const want = "* Lorem ipsum dolor\n  sit amet, lectus-\n  sed-ut-at-lacinia."
+	opts := []wordwrap.Option{
+		wordwrap.WithFirstLinePrefix("* "),
+		wordwrap.WithPrefix("  "),
+		wordwrap.WithTabWidth(4),
+		wordwrap.WithBreakpoints("-"),
+	}
+	if got := wordwrap.String(source, 20, opts...); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := string(wordwrap.Bytes([]byte(source), 20, opts...)); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	upper := func(w *wordwrap.Writer) { w.SetTransform(strings.ToUpper) }
+	if got := wordwrap.String("Lorem ipsum", 20, upper); got != "LOREM IPSUM" {
+		t.Errorf("got %q, want %q", got, "LOREM IPSUM")
+	}
+}
+
+func TestPreserveTableRows(t *testing.T) {
+	const source = "Lorem ipsum dolor sit amet, lectus sed ut at lacinia.\n" +
+		"+------+------+------+------+\n" +
+		"-- -- -- -- -- -- -- -- -- --\n" +
+		"│ a │ b │\n" +
+		"└───┴───┴───┴───┴───┴───┴───┘\n" +
+		"A adipiscing."
+	const want = "Lorem ipsum dolor\n> sit amet, lectus\n> sed ut at lacinia.\n" +
+		"> +------+------+------+------+\n" +
+		"> -- -- -- -- -- -- -- -- -- --\n" +
+		"> │ a │ b │\n" +
+		"> └───┴───┴───┴───┴───┴───┴───┘\n" +
+		"> A adipiscing."
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 20)
+	w.SetPrefix("> ")
+	w.SetPreserveTableRows(true)
+	if _, err := w.WriteString(source); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	w = wordwrap.New(&buf, 20)
+	w.SetFill(true)
+	w.SetPreserveTableRows(true)
+	w.WriteString("Lorem ipsum\ndolor sit\n+---+---+\n\nA adipiscing.\n+---+")
+	w.Flush()
+	const fill = "Lorem ipsum dolor\nsit\n+---+---+\n\nA adipiscing.\n+---+"
+	if got := buf.String(); got != fill {
+		t.Errorf("fill: got %q, want %q", got, fill)
+	}
+}
+
+func TestPreferBreakpoints(t *testing.T) {
+	const source = "anti-disestablishment-arianism is long"
+	for _, test := range []struct {
+		width  uint
+		prefer bool
+		want   string
+	}{
+		{32, false, "anti-disestablishment-arianism\nis long"},
+		{32, true, "anti-disestablishment-\narianism is long"},
+		{36, false, "anti-disestablishment-arianism is\nlong"},
+		{36, true, "anti-disestablishment-\narianism is long"},
+		{10, true, "anti-\ndisestablishment-\narianism\nis long"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, test.width)
+		w.SetBreakpoints("-")
+		w.SetPreferBreakpoints(test.prefer)
+		if _, err := w.WriteString(source); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("width %d, prefer %v: got %q, want %q",
+				test.width, test.prefer, got, test.want)
+		}
+	}
+}
+
+func TestBreaks(t *testing.T) {
+	const source = "Lorem ipsum dolor sit amet,\nlectus sed ut at lacinia.\n\nEnd"
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 20)
+	w.SetPrefix("> ")
+	w.SetCollectBreaks(true)
+	if _, err := w.WriteString(source); err != nil {
+		t.Fatal(err)
+	}
+	// "Lorem ipsum dolor\n> sit amet,\n> lectus sed ut at\n> lacinia.\n> \n> End"
+	want := []wordwrap.Break{
+		{Offset: 17, Inserted: true},
+		{Offset: 29, Inserted: false},
+		{Offset: 48, Inserted: true},
+		{Offset: 59, Inserted: false},
+		{Offset: 62, Inserted: false},
+	}
+	got := w.Breaks()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, b := range got {
+		if buf.Bytes()[b.Offset] != '\n' {
+			t.Errorf("no newline at offset %d of %q", b.Offset, buf.String())
+		}
+	}
+	w.Reset()
+	if got := w.Breaks(); len(got) != 0 {
+		t.Errorf("breaks are not cleared: %v", got)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	const source = "Lorem ipsum dolor sit amet, lectus sed ut at lacinia."
+	for _, test := range []struct {
+		source    string
+		limit     int
+		ellipsis  string
+		want      string
+		truncated bool
+	}{
+		{source, 0, "", "Lorem ipsum dolor\n> sit amet, lectus\n> sed ut at lacinia.", false},
+		{source, 100, "…", "Lorem ipsum dolor\n> sit amet, lectus\n> sed ut at lacinia.", false},
+		{source, 22, "", "Lorem ipsum dolor\n> si", true},
+		{source, 22, "...", "Lorem ipsum dolor\n>...", true},
+		{source, 60, "...", "Lorem ipsum dolor\n> sit amet, lectus\n> sed ut at lacinia.", false},
+		{"Съешь же ещё этих мягких", 9, "", "Съеш", true},
+		{"Съешь же ещё этих мягких", 9, "…", "Съе…", true},
+		{source, 2, "...", "", true},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 20)
+		w.SetPrefix("> ")
+		w.SetMaxBytes(test.limit)
+		w.SetEllipsis(test.ellipsis)
+		n, err := w.WriteString(test.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(test.source) {
+			t.Errorf("got %d bytes, want %d", n, len(test.source))
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%d %q: got %q, want %q", test.limit, test.ellipsis, got, test.want)
+		}
+		if w.Truncated() != test.truncated {
+			t.Errorf("%d %q: truncated %v", test.limit, test.ellipsis, w.Truncated())
+		}
+	}
+}
+
+func TestObjectWidth(t *testing.T) {
+	const img = "\x00IMG\x00"
+	for _, test := range []struct {
+		source string
+		want   string
+	}{
+		// the object of 8 columns does not fit: 13+8 > 20
+		{"Lorem ipsum, " + img + " dolor", "Lorem ipsum,\n" + img + " dolor"},
+		{"Lorem " + img + " dolor sit", "Lorem " + img + " dolor\nsit"},
+		{"Lorem ipsum (" + img + ")", "Lorem ipsum\n(" + img + ")"},
+		{img + img + img, img + img + img},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 20)
+		w.SetObjectWidth(img, 8)
+		if _, err := w.WriteString(test.source); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestKeepPreNewlineSpace(t *testing.T) {
+	// the spaces end before, at and after the width
+	for _, source := range []string{"Lorem ipsum \nx", "Lorem ipsum  \nx",
+		"Lorem ipsum    \nx", "Lorem ipsum\t \nx", "   \nx"} {
+		for _, width := range []uint{5, 12, 13, 14, 20} {
+			for _, keep := range []bool{false, true} {
+				var buf bytes.Buffer
+				w := wordwrap.New(&buf, width)
+				w.SetPrefix("> ")
+				w.SetKeepPreNewlineSpace(keep)
+				if _, err := w.WriteString(source); err != nil {
+					t.Fatal(err)
+				}
+				text := strings.TrimRight(source, " \t\nx")
+				want := wordwrap.String(text, width, wordwrap.WithPrefix("> "))
+				if keep {
+					want += source[len(text) : len(source)-2]
+				}
+				want += "\n> x"
+				if got := buf.String(); got != want {
+					t.Errorf("width %d, keep %v, %q: got %q, want %q",
+						width, keep, source, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestMargin(t *testing.T) {
+	const source = "Lorem ipsum dolor sit amet, lectus sed ut at lacinia.\n\nEnd"
+	for _, test := range []struct {
+		width  uint
+		bullet string
+		want   string
+	}{
+		{24, "", "    Lorem ipsum dolor\n    sit amet, lectus sed\n" +
+			"    ut at lacinia.\n    \n    End"},
+		{24, "• ", "    • Lorem ipsum dolor\n      sit amet, lectus\n" +
+			"      sed ut at lacinia.\n      \n      End"},
+		{0, "• ", "    • Lorem ipsum dolor sit amet, lectus sed ut at lacinia.\n" +
+			"      \n      End"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, test.width)
+		w.SetMargin(4)
+		w.SetHangingBullet(test.bullet)
+		if _, err := w.WriteString(source); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%d %q: got %q, want %q", test.width, test.bullet, got, test.want)
+		}
+	}
+	w := wordwrap.New(ioutil.Discard, 10)
+	w.SetMargin(8)
+	w.SetPrefix("> ")
+	if err := w.Validate(); err != wordwrap.ErrNoContentWidth {
+		t.Errorf("got %v, want %v", err, wordwrap.ErrNoContentWidth)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	const source = "Lorem \xff\xfe\xfd ipsum do\x80lor sit amet"
+	for _, test := range []struct {
+		mode wordwrap.InvalidUTF8
+		want string
+	}{
+		{wordwrap.InvalidReplace, "Lorem ��� ipsum\ndo�lor sit amet"},
+		{wordwrap.InvalidCollapse, "Lorem � ipsum\ndo�lor sit amet"},
+		// the raw bytes have no width
+		{wordwrap.InvalidPass, "Lorem \xff\xfe\xfd ipsum do\x80lor\nsit amet"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 19)
+		w.SetInvalidUTF8(test.mode)
+		n, err := w.WriteString(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(source) {
+			t.Errorf("got %d bytes, want %d", n, len(source))
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("mode %d: got %q, want %q", test.mode, got, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		mode wordwrap.InvalidUTF8
+		want string
+	}{
+		{wordwrap.InvalidReplace, "ab\uFFFD\uFFFDc\nd"},
+		{wordwrap.InvalidCollapse, "ab\uFFFDcd"},
+		{wordwrap.InvalidPass, "ab\xff\xfecd"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 5)
+		w.SetVerbatim(true)
+		w.SetInvalidUTF8(test.mode)
+		w.WriteString("ab\xff\xfecd")
+		if got := buf.String(); got != test.want {
+			t.Errorf("verbatim, mode %d: got %q, want %q", test.mode, got, test.want)
+		}
+	}
+}
+
+func TestSetWidth(t *testing.T) {
+	const prose = "Lorem ipsum dolor sit amet, lectus sed ut at lacinia."
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 30)
+	w.SetWidthForNext(20) // nothing is written yet
+	w.WriteString(prose + "\n")
+	w.SetWidth(0)
+	w.WriteString(prose + "\n")
+	w.SetWidth(30)
+	w.WriteString("Lorem ipsum dolor sit amet,")
+	w.SetWidthForNext(15) // the current line is not affected
+	w.WriteString(" lectus sed ut at lacinia.")
+	const want = "Lorem ipsum dolor\nsit amet, lectus sed\nut at lacinia.\n" +
+		prose + "\n" +
+		"Lorem ipsum dolor sit amet,\nlectus sed ut\nat lacinia."
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAtLineStart(t *testing.T) {
+	for _, test := range []struct {
+		source []string
+		fill   bool
+		want   bool
+	}{
+		{nil, false, true},
+		{[]string{""}, false, true},
+		{[]string{"Lorem"}, false, false},
+		{[]string{"Lorem\n"}, false, true},
+		{[]string{"Lorem\n", "ipsum"}, false, false},
+		{[]string{"Lorem ipsum dolor sit amet,\n"}, false, true},
+		{[]string{"Lorem\n "}, false, false},
+		{[]string{"Lorem\n\n"}, false, true},
+		{[]string{"Lorem\n"}, true, false},
+		{[]string{"Lorem\n\n"}, true, true},
+	} {
+		w := wordwrap.New(ioutil.Discard, 20)
+		w.SetFill(test.fill)
+		for _, s := range test.source {
+			if _, err := w.WriteString(s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := w.AtLineStart(); got != test.want {
+			t.Errorf("%q, fill %v: got %v, want %v", test.source, test.fill, got, test.want)
+		}
+	}
+	w := wordwrap.New(ioutil.Discard, 20)
+	w.SetDirection(wordwrap.RTL)
+	w.WriteString("Lorem\n")
+	if !w.AtLineStart() {
+		t.Error("buffered line: got false after newline")
+	}
+	w.WriteString("ipsum")
+	if w.AtLineStart() {
+		t.Error("buffered line: got true with line content")
+	}
+}
+
+func TestBreakpointValidation(t *testing.T) {
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 10)
+	w.SetBreakpoints("--/")
+	if err := w.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	w.SetBreakpoints("- \n/")
+	if err := w.Validate(); err != wordwrap.ErrIgnoredBreakpoints {
+		t.Errorf("got error %v, want %v", err, wordwrap.ErrIgnoredBreakpoints)
+	}
+	w.WriteString("Lorem-ipsum/dolor sit\namet")
+	const want = "Lorem-\nipsum/\ndolor sit\namet"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	w.SetBreakpoints("-")
+	if err := w.Validate(); err != nil {
+		t.Errorf("error is not cleared: %v", err)
+	}
+}
+
+func TestLeadingBreakpoints(t *testing.T) {
+	const source = "The plan—which nobody liked—was approved—eventually—by everyone"
+	for _, test := range []struct {
+		leading bool
+		want    string
+	}{
+		{false, "The plan—which\n> nobody liked—was\n> approved—\n> eventually—by\n> everyone"},
+		{true, "The plan—which\n> nobody liked—was\n> approved\n> —eventually—by\n> everyone"},
+	} {
+		var buf bytes.Buffer
+		w := wordwrap.New(&buf, 20)
+		w.SetPrefix("> ")
+		if test.leading {
+			w.SetLeadingBreakpoints("—")
+		} else {
+			w.SetBreakpoints("—")
+		}
+		if _, err := w.WriteString(source); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("leading %v: got %q, want %q", test.leading, got, test.want)
+		}
+	}
+}
+
+func TestWrapPositions(t *testing.T) {
+	const source = "Lorem ipsum dolor sit amet,\nlectus-sed-ut-at lacinia.\tEnd"
+	got := wordwrap.WrapPositions(source, 20)
+	// "Lorem ipsum dolor|\nsit amet,\nlectus-sed-ut-at|\nlacinia. End"
+	want := []int{17, 44}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 16)
+	w.SetPrefix("> ")
+	w.SetTabWidth(8)
+	w.SetBreakpoints("-")
+	got = w.WrapPositions(source)
+	// "Lorem ipsum|\n> dolor sit|\n> amet,\n> lectus-sed-ut-|\n> at lacinia.|\n> End"
+	want = []int{11, 21, 42, 53}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+	for _, width := range []uint{5, 10, 15, 25} {
+		lines := strings.Split(wordwrap.String(source, width), "\n")
+		var rest = source
+		for i, pos := range append(wordwrap.WrapPositions(source, width), len(source)) {
+			line := strings.TrimLeft(source[len(source)-len(rest):pos], " \t")
+			rest = source[pos:]
+			for _, l := range strings.Split(line, "\n") {
+				if len(lines) == 0 || lines[0] != l {
+					t.Errorf("width %d, position %d: got %q, want %q", width, i, l, lines)
+					break
+				}
+				lines = lines[1:]
+			}
+		}
+	}
+}
+
+func TestWriteString(t *testing.T) {
+	const source = "\uFEFFLorem ipsum dolor sit amet, \x1b[1mlectus\x1b[0m sed\tut at\n" +
+		"lacinia. A adipi\xffscing-vel placerat."
+	configs := []func(w *wordwrap.Writer){
+		func(w *wordwrap.Writer) {},
+		func(w *wordwrap.Writer) { w.SetPrefix("> "); w.SetTabWidth(4) },
+		func(w *wordwrap.Writer) { w.SetBreakpoints("-"); w.SetStripBOM(true) },
+		func(w *wordwrap.Writer) { w.SetFill(true); w.SetHangingIndent(2) },
+		func(w *wordwrap.Writer) { w.SetVerbatim(true) },
+	}
+	for i, config := range configs {
+		for _, width := range []uint{0, 10, 20} {
+			var want, got bytes.Buffer
+			ww := wordwrap.New(&want, width)
+			config(ww)
+			wn, werr := ww.Write([]byte(source))
+			ws := wordwrap.New(&got, width)
+			config(ws)
+			sn, serr := ws.WriteString(source)
+			if got.String() != want.String() || sn != wn || serr != werr {
+				t.Errorf("config %d, width %d: got %q, %d, %v, want %q, %d, %v",
+					i, width, got.String(), sn, serr, want.String(), wn, werr)
+			}
+		}
+	}
+}
+
+// cancelWriter cancels the context on the first write.
+type cancelWriter struct {
+	bytes.Buffer
+	cancel context.CancelFunc
+}
+
+func (w *cancelWriter) Write(b []byte) (int, error) {
+	w.cancel()
+	return w.Buffer.Write(b)
+}
+
+func TestWriteContext(t *testing.T) {
+	source := []byte(strings.Repeat("Lorem ipsum dolor sit amet, lectus sed ut at lacinia.\n", 5000))
+	want := wordwrap.String(string(source), 20)
+
+	var buf bytes.Buffer
+	w := wordwrap.New(&buf, 20)
+	n, err := w.WriteContext(context.Background(), source)
+	if err != nil || n != len(source) {
+		t.Errorf("got %d, %v, want %d, nil", n, err, len(source))
+	}
+	if got := buf.String(); got != want {
+		t.Error("wrong output")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	buf.Reset()
+	w = wordwrap.New(&buf, 20)
+	if n, err := w.WriteContext(ctx, source); n != 0 || err != context.Canceled {
+		t.Errorf("got %d, %v, want 0, %v", n, err, context.Canceled)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	dst := &cancelWriter{cancel: cancel}
+	w = wordwrap.New(dst, 20)
+	n, err = w.WriteContext(ctx, source)
+	if err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if n == 0 || n >= len(source) {
+		t.Fatalf("got %d bytes of %d", n, len(source))
+	}
+	// resume the wrapping
+	if _, err := w.Write(source[n:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := dst.String(); got != want {
+		t.Error("wrong output after resume")
+	}
+
+	source = []byte(strings.Repeat("aaa bbb ccc ddd eee fff ", 4000) +
+		strings.Repeat("x", 70000) + " end ")
+	var got, single bytes.Buffer
+	for _, dst := range []*bytes.Buffer{&got, &single} {
+		w = wordwrap.New(dst, 17)
+		w.SetKeepFinalSpace(true)
+		if dst == &got {
+			w.WriteContext(context.Background(), source)
+		} else {
+			w.Write(source)
+		}
+		w.Flush()
+	}
+	if got.String() != single.String() {
+		t.Error("output differs from a single Write")
+	}
+}
+
+func BenchmarkWriteString(b *testing.B) {
+	source := strings.Repeat("Lorem ipsum dolor sit amet, lectus sed ut at lacinia. ", 1000)
+	w := wordwrap.New(ioutil.Discard, 80)
+	w.SetPrefix("> ")
+	b.ReportAllocs()
+	b.SetBytes(int64(len(source)))
+	for i := 0; i < b.N; i++ {
+		w.Reset()
+		w.WriteString(source)
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	source := strings.Repeat("Lorem ipsum dolor sit amet, lectus sed ut at lacinia. ", 1000)
+	w := wordwrap.New(ioutil.Discard, 80)
+	w.SetPrefix("> ")
+	b.ReportAllocs()
+	b.SetBytes(int64(len(source)))
+	for i := 0; i < b.N; i++ {
+		w.Reset()
+		w.Write([]byte(source))
+	}
+}